@@ -7,4 +7,6 @@ require (
 	github.com/likexian/whois v1.15.6
 )
 
-require golang.org/x/net v0.35.0 // indirect
+require golang.org/x/net v0.35.0
+
+require golang.org/x/text v0.22.0 // indirect
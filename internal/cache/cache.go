@@ -1,22 +1,38 @@
 package cache
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
 	"sync"
 	"time"
+
+	"domain_scanner/internal/types"
 )
 
 // DomainCache is a thread-safe cache for domain check results
 type DomainCache struct {
-	mu    sync.RWMutex
-	cache map[string]*CacheEntry
-	ttl   time.Duration
+	mu     sync.RWMutex
+	cache  map[string]*CacheEntry
+	ttl    time.Duration
+	ttls   map[types.Status]time.Duration // Per-verdict TTLs overriding ttl
+	log    *os.File                       // Append-only log backing a persistent cache
+	checks string                         // Checker set of this run, see SetChecks
 }
 
 // CacheEntry represents a cached domain check result
 type CacheEntry struct {
-	Available  bool
-	Signatures []string
-	Timestamp  time.Time
+	Domain       string              `json:"domain"`
+	Status       types.Status        `json:"status"`
+	Reason       string              `json:"reason,omitempty"`
+	Signatures   []string            `json:"signatures,omitempty"`
+	Server       string              `json:"server,omitempty"`
+	Registration *types.Registration `json:"registration,omitempty"`
+	Confidence   float64             `json:"confidence,omitempty"`
+	Verified     bool                `json:"verified,omitempty"`
+	Checks       string              `json:"checks,omitempty"` // Checker set that produced the verdict
+	Timestamp    time.Time           `json:"timestamp"`
 }
 
 // NewDomainCache creates a new domain cache with specified TTL
@@ -24,37 +40,174 @@ func NewDomainCache(ttl time.Duration) *DomainCache {
 	return &DomainCache{
 		cache: make(map[string]*CacheEntry),
 		ttl:   ttl,
+		ttls:  make(map[types.Status]time.Duration),
+	}
+}
+
+// OpenDomainCache creates a cache persisted to an append-only log file.
+// Existing entries are loaded (later lines win), expired ones dropped and the
+// log rewritten compactly before new results are appended to it.
+func OpenDomainCache(path string, ttl time.Duration, ttls map[types.Status]time.Duration) (*DomainCache, error) {
+	dc := NewDomainCache(ttl)
+	for status, statusTTL := range ttls {
+		dc.ttls[status] = statusTTL
+	}
+
+	if err := dc.load(path); err != nil {
+		return nil, err
+	}
+	if err := dc.compact(path); err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache file: %w", err)
+	}
+	dc.log = log
+	return dc, nil
+}
+
+// load reads the log file into memory, skipping expired and malformed entries
+func (dc *DomainCache) load(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open cache file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry CacheEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Domain == "" {
+			continue // A partially written last line from an interrupted run
+		}
+		if dc.expired(&entry, time.Now()) {
+			delete(dc.cache, entry.Domain)
+			continue
+		}
+		dc.cache[entry.Domain] = &entry
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading cache file: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the log with only the live entries
+func (dc *DomainCache) compact(path string) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact cache file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range dc.cache {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to compact cache file: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to compact cache file: %w", err)
 	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to compact cache file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// SetTTL sets the TTL for results with the given verdict. A TTL of zero
+// disables caching of that verdict.
+func (dc *DomainCache) SetTTL(status types.Status, ttl time.Duration) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.ttls[status] = ttl
+}
+
+// SetChecks sets the checker set of this run, e.g. "DNS_NS,WHOIS". Entries
+// stored by a run with other checkers are not returned by Get, so a verdict
+// of a reduced pipeline is not reused by a full one; Set stamps new entries.
+func (dc *DomainCache) SetChecks(checks string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.checks = checks
+}
+
+// ttlFor returns the TTL that applies to a verdict
+func (dc *DomainCache) ttlFor(status types.Status) time.Duration {
+	if ttl, ok := dc.ttls[status]; ok {
+		return ttl
+	}
+	return dc.ttl
+}
+
+func (dc *DomainCache) expired(entry *CacheEntry, now time.Time) bool {
+	return now.Sub(entry.Timestamp) > dc.ttlFor(entry.Status)
 }
 
 // Get retrieves a cached result if it exists and is not expired
-func (dc *DomainCache) Get(domain string) (available bool, signatures []string, found bool) {
+func (dc *DomainCache) Get(domain string) (entry CacheEntry, found bool) {
 	dc.mu.RLock()
 	defer dc.mu.RUnlock()
 
-	entry, exists := dc.cache[domain]
-	if !exists {
-		return false, nil, false
+	cached, exists := dc.cache[domain]
+	if !exists || cached.Checks != dc.checks {
+		return CacheEntry{}, false
 	}
 
 	// Check if entry is expired
-	if time.Since(entry.Timestamp) > dc.ttl {
-		return false, nil, false
+	if dc.expired(cached, time.Now()) {
+		return CacheEntry{}, false
+	}
+
+	return *cached, true
+}
+
+// Set stores a domain check result in the cache, appending it to the log
+// of a persistent cache. The entry's Timestamp is set to the current time.
+func (dc *DomainCache) Set(entry CacheEntry) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if dc.ttlFor(entry.Status) <= 0 {
+		return nil
 	}
 
-	return entry.Available, entry.Signatures, true
+	entry.Timestamp = time.Now()
+	entry.Checks = dc.checks
+	dc.cache[entry.Domain] = &entry
+
+	if dc.log != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := dc.log.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write cache file: %w", err)
+		}
+	}
+	return nil
 }
 
-// Set stores a domain check result in the cache
-func (dc *DomainCache) Set(domain string, available bool, signatures []string) {
+// Close closes the log file of a persistent cache
+func (dc *DomainCache) Close() error {
 	dc.mu.Lock()
 	defer dc.mu.Unlock()
 
-	dc.cache[domain] = &CacheEntry{
-		Available:  available,
-		Signatures: signatures,
-		Timestamp:  time.Now(),
+	if dc.log == nil {
+		return nil
 	}
+	err := dc.log.Close()
+	dc.log = nil
+	return err
 }
 
 // Clean removes expired entries from the cache
@@ -64,7 +217,7 @@ func (dc *DomainCache) Clean() {
 
 	now := time.Now()
 	for domain, entry := range dc.cache {
-		if now.Sub(entry.Timestamp) > dc.ttl {
+		if dc.expired(entry, now) {
 			delete(dc.cache, domain)
 		}
 	}
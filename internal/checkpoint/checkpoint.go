@@ -0,0 +1,175 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const stateVersion = 1
+
+// Params are the scan parameters a checkpoint is only valid for
+type Params struct {
+	Length    int    `json:"length"`
+	MaxLength int    `json:"max_length,omitempty"` // Set when -l is a range
+	Hyphens   bool   `json:"hyphens,omitempty"`
+	Suffix    string `json:"suffix"`
+	Pattern   string `json:"pattern"`
+	Regex     string `json:"regex"`
+	Dict      string `json:"dict"`
+	Mask      string `json:"mask,omitempty"`
+	Charsets  string `json:"charsets,omitempty"`
+}
+
+// State is the persisted progress of a scan
+type State struct {
+	Version    int               `json:"version"`
+	Params     Params            `json:"params"`
+	NextIndex  int64             `json:"next_index"` // Every candidate below this index has been checked
+	Completed  []int64           `json:"completed"`  // Candidates at or above NextIndex checked out of order
+	Checked    int               `json:"checked"`    // Number of domains checked so far
+	Available  []string          `json:"available"`
+	Registered []string          `json:"registered"`
+	Unknown    []string          `json:"unknown"`
+	Dropping   []string          `json:"dropping,omitempty"`   // Lines of the dropping soon report
+	Unverified []string          `json:"unverified,omitempty"` // Available domains below -min-confidence
+	Matrix     map[string]string `json:"matrix,omitempty"`     // TLD matrix cells of a multi-TLD scan by domain
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// New returns an empty state for a scan with the given parameters
+func New(params Params) *State {
+	return &State{Version: stateVersion, Params: params}
+}
+
+// Load reads a checkpoint file
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file: %w", err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", state.Version)
+	}
+	return &state, nil
+}
+
+// Validate checks that the checkpoint was written by a scan with the same parameters
+func (s *State) Validate(params Params) error {
+	if s.Params != params {
+		return fmt.Errorf("checkpoint parameters %+v do not match current scan %+v", s.Params, params)
+	}
+	return nil
+}
+
+// Save writes the state to path atomically, so that a crash while saving
+// never leaves a truncated checkpoint behind
+func (s *State) Save(path string) error {
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Tracker follows which candidates have been dispatched and completed and
+// keeps the state's resume cursor up to date. Candidate indexes must be
+// dispatched in increasing order.
+type Tracker struct {
+	mu      sync.Mutex
+	state   *State
+	pending []int64        // Dispatched but not yet folded into NextIndex, in order
+	done    map[int64]bool // Completed at or above NextIndex
+}
+
+// NewTracker starts tracking from the given state
+func NewTracker(state *State) *Tracker {
+	t := &Tracker{
+		state: state,
+		done:  make(map[int64]bool, len(state.Completed)),
+	}
+	for _, index := range state.Completed {
+		t.done[index] = true
+	}
+	return t
+}
+
+// Done reports whether the candidate was already checked by a previous run
+func (t *Tracker) Done(index int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return index < t.state.NextIndex || t.done[index]
+}
+
+// Dispatched records that the candidate has been handed to a worker
+func (t *Tracker) Dispatched(index int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, index)
+}
+
+// Completed records that the candidate has been checked and advances the
+// cursor over the contiguous run of completed candidates
+func (t *Tracker) Completed(index int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done[index] = true
+	t.state.Checked++
+	for len(t.pending) > 0 && t.done[t.pending[0]] {
+		t.state.NextIndex = t.pending[0] + 1
+		delete(t.done, t.pending[0])
+		t.pending = t.pending[1:]
+	}
+}
+
+// Results are the result lists of a scan, saved with its progress
+type Results struct {
+	Available  []string
+	Registered []string
+	Unknown    []string
+	Dropping   []string
+	Unverified []string
+	Matrix     map[string]string
+}
+
+// Save writes the current progress together with the results collected so far
+func (t *Tracker) Save(path string, results Results) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state.Completed = t.state.Completed[:0]
+	for index := range t.done {
+		if index < t.state.NextIndex {
+			delete(t.done, index)
+			continue
+		}
+		t.state.Completed = append(t.state.Completed, index)
+	}
+	sort.Slice(t.state.Completed, func(i, j int) bool { return t.state.Completed[i] < t.state.Completed[j] })
+
+	t.state.Available = results.Available
+	t.state.Registered = results.Registered
+	t.state.Unknown = results.Unknown
+	t.state.Dropping = results.Dropping
+	t.state.Unverified = results.Unverified
+	t.state.Matrix = results.Matrix
+	return t.state.Save(path)
+}
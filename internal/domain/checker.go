@@ -1,35 +1,16 @@
 package domain
 
 import (
-	"crypto/tls"
-	"net"
+	"context"
+	"fmt"
 	"strings"
-	"sync"
 	"time"
 
-	"domain_scanner/internal/reserved"
-
-	"github.com/likexian/whois"
+	"domain_scanner/internal/types"
+	"domain_scanner/internal/whoisparser"
 )
 
 var (
-	// Pre-initialized maps for O(1) lookup
-	availableIndicatorsMap   map[string]bool
-	unavailableIndicatorsMap map[string]bool
-	indicatorsOnce           sync.Once
-
-	// WHOIS servers for domain availability checking
-	whoisServers = []string{
-		"", // Default flow (IANA lookup)
-		"whois.nic.li:43",
-		"whois.nic.cx:43",        // Christmas Island
-		"whois.nic.cz:43",        // Czech Republic
-		"whois.verisign-grs.com:43",
-		"whois.porkbun.com:43",
-		"whois.godaddy.com:43",
-		"whois.internic.net:43",
-	}
-
 	// WHOIS indicators for domain status detection
 	registeredIndicators = []string{
 		"registrar:",
@@ -41,6 +22,11 @@ var (
 		"nserver:",
 		"status: connect",
 		"changed:",
+		// Lifecycle states of a registered domain on its way to deletion
+		"status: redemption",
+		"status: grace period",
+		"status: pending delete",
+		"status: pending restore",
 	}
 
 	reservedIndicators = []string{
@@ -77,10 +63,6 @@ var (
 		"status: locked",
 		"status: suspended",
 		"status: quarantine",
-		"status: redemption",
-		"status: grace period",
-		"status: pending delete",
-		"status: pending restore",
 		"status: clienthold",
 		"status: serverhold",
 		"status: clienttransferprohibited",
@@ -189,6 +171,15 @@ var (
 		"service timeout",
 	}
 
+	// Subset of service errors that mean the server is throttling us
+	rateLimitIndicators = []string{
+		"requests of this client are not permitted",
+		"too many requests",
+		"rate limit exceeded",
+		"query limit exceeded",
+		"number of allowed queries exceeded",
+	}
+
 	unavailableIndicators = []string{
 		"registrar:", "registrant:", "creation date:", "updated date:",
 		"expiration date:", "name server:", "nserver:", "status: registered",
@@ -216,241 +207,185 @@ var (
 	}
 )
 
-// initIndicatorMaps initializes the indicator maps for fast lookup
-func initIndicatorMaps() {
-	indicatorsOnce.Do(func() {
-		// Initialize available indicators map
-		availableIndicatorsMap = make(map[string]bool, len(availableIndicators))
-		for _, indicator := range availableIndicators {
-			availableIndicatorsMap[indicator] = true
-		}
-
-		// Initialize unavailable indicators map
-		unavailableIndicatorsMap = make(map[string]bool, len(unavailableIndicators))
-		for _, indicator := range unavailableIndicators {
-			unavailableIndicatorsMap[indicator] = true
-		}
-	})
-}
-
+// CheckDomainSignatures runs the default pipeline and returns the registration signatures found
 func CheckDomainSignatures(domain string) ([]string, error) {
-	var signatures []string
-
-	// 1. Check DNS NS records
-	nsRecords, err := net.LookupNS(domain)
-	if err == nil && len(nsRecords) > 0 {
-		signatures = append(signatures, "DNS_NS")
-	}
+	return DefaultPipeline().Check(context.Background(), domain).Signatures, nil
+}
 
-	// 2. Check DNS A records
-	ipRecords, err := net.LookupIP(domain)
-	if err == nil && len(ipRecords) > 0 {
-		signatures = append(signatures, "DNS_A")
-	}
+// CheckDomainAvailability runs the default pipeline and reports whether the domain is available
+func CheckDomainAvailability(domain string) (bool, error) {
+	return DefaultPipeline().Check(context.Background(), domain).Status == types.StatusAvailable, nil
+}
 
-	// 3. Check DNS MX records
-	mxRecords, err := net.LookupMX(domain)
-	if err == nil && len(mxRecords) > 0 {
-		signatures = append(signatures, "DNS_MX")
+// checkWHOIS queries the authoritative WHOIS servers of the domain's TLD in
+// turn until one gives a clear answer. Servers paused by their circuit breaker
+// are skipped; if no server could answer because of that, the domain is
+// reported as rate limited so that it gets re-queued.
+func checkWHOIS(ctx context.Context, domain string) types.Evidence {
+	servers := whoisServersFor(ctx, domain)
+	if len(servers) == 0 {
+		return types.Evidence{Err: fmt.Errorf("no WHOIS server known for .%s", tldOf(domain))}
 	}
+	return checkWHOISServers(ctx, domain, servers)
+}
 
-	// 4. Check WHOIS information with retry
+// checkWHOISServers asks the given WHOIS servers in turn, see checkWHOIS
+func checkWHOISServers(ctx context.Context, domain string, servers []string) types.Evidence {
 	maxRetries := 3
 	baseDelay := 2 * time.Second
+	foundAnyResult := false
+	pausedServer := ""
+	var pausedFor time.Duration
+	lastRaw := "" // Last answer, kept for the evidence if no server was clear
+
+	for _, server := range servers {
+		if cooldown, ok := serverAllowed(server); !ok {
+			pausedServer, pausedFor = server, cooldown
+			continue
+		}
 
-	for _, server := range whoisServers {
 		for i := 0; i < maxRetries; i++ {
-			var result string
-			var err error
-			if server == "" {
-				result, err = whois.Whois(domain)
-			} else {
-				result, err = whois.Whois(domain, server)
-			}
+			result, err := queryWHOIS(ctx, domain, server)
 
 			if err == nil && result != "" {
+				foundAnyResult = true
+				lastRaw = result
 				resultLower := strings.ToLower(result)
+				detail := "WHOIS " + server
+
+				// FIRST: Check for service errors (should NOT be treated as "available")
+				if isRateLimited(resultLower) {
+					// Pause the server and try the next one
+					serverRateLimited(server)
+					pausedServer, pausedFor = server, ServerCooldown(server)
+					break
+				}
+				if isServiceError(resultLower) {
+					// Service error - stop here to prevent false positives.
+					// It counts as a failure, so a server that keeps answering
+					// with errors is paused like one that does not answer.
+					serverErrored(server)
+					return types.Evidence{Server: server, Detail: detail, Raw: result, Err: fmt.Errorf("WHOIS service error from %s", detail)}
+				}
+				serverAnswered(server)
+				registration := whoisparser.Parse(result)
+				if registration != nil {
+					registration.Source = server
+				}
+
 				// Check for registered indicators
 				for _, indicator := range registeredIndicators {
 					if strings.Contains(resultLower, indicator) {
-						signatures = append(signatures, "WHOIS")
-						return signatures, nil // Found registered, return immediately
+						return types.Evidence{Server: server, Signature: "WHOIS", Status: types.StatusRegistered, Conclusive: true, Detail: detail + " matched " + indicator, Matched: indicator, Raw: result, Registration: registration}
 					}
 				}
 
 				// Check for reserved indicators
 				for _, indicator := range reservedIndicators {
 					if strings.Contains(resultLower, indicator) {
-						signatures = append(signatures, "RESERVED")
-						return signatures, nil // Found reserved, return immediately
+						status := types.StatusReserved
+						if strings.Contains(indicator, "premium") {
+							status = types.StatusPremium
+						}
+						return types.Evidence{Server: server, Signature: "RESERVED", Status: status, Conclusive: true, Detail: detail + " matched " + indicator, Matched: indicator, Raw: result, Registration: registration}
 					}
 				}
 
-				// If we get here, the result was unclear, try next server
-				break
-			}
-
-			// If there are still retry attempts, use exponential backoff
-			if i < maxRetries-1 {
-				// Calculate exponential delay: baseDelay * 2^i
-				delay := baseDelay * time.Duration(1<<i)
-				time.Sleep(delay)
-			}
-		}
-		if server != "" {
-			time.Sleep(1 * time.Second) // Delay before trying next server
-		}
-	}
-
-	// 5. Check SSL certificate with timeout
-	conn, err := tls.DialWithDialer(&net.Dialer{
-		Timeout: 5 * time.Second,
-	}, "tcp", domain+":443", &tls.Config{
-		InsecureSkipVerify: true,
-	})
-	if err == nil {
-		defer conn.Close()
-		state := conn.ConnectionState()
-		if len(state.PeerCertificates) > 0 {
-			signatures = append(signatures, "SSL")
-		}
-	}
-
-	return signatures, nil
-}
-
-func CheckDomainAvailability(domain string) (bool, error) {
-	// First check if domain is reserved by pattern or TLD rules
-	if reserved.IsReservedDomain(domain) {
-		return false, nil
-	}
-
-	signatures, err := CheckDomainSignatures(domain)
-	if err != nil {
-		return false, err
-	}
-
-	// Check for reserved signature
-	for _, sig := range signatures {
-		if sig == "RESERVED" {
-			return false, nil
-		}
-	}
-
-	// If any other signatures found, domain is registered
-	if len(signatures) > 0 {
-		return false, nil
-	}
-
-	// Final WHOIS check for availability
-	return checkWHOISAvailability(domain)
-}
-
-func checkWHOISAvailability(domain string) (bool, error) {
-	maxRetries := 3
-	baseDelay := 2 * time.Second
-	foundAnyResult := false
-
-	for _, server := range whoisServers {
-		for i := 0; i < maxRetries; i++ {
-			var result string
-			var err error
-			if server == "" {
-				result, err = whois.Whois(domain)
-			} else {
-				result, err = whois.Whois(domain, server)
-			}
-
-			if err == nil && result != "" {
-				foundAnyResult = true
-				resultLower := strings.ToLower(result)
-
-				// FIRST: Check for service errors (should NOT be treated as "available")
-				if isServiceError(resultLower) {
-					// Service error - treat as unavailable to prevent false positives
-					return false, nil
+				// Only report available if we have an explicit "available" signal
+				if indicator := availableIndicator(resultLower); indicator != "" {
+					return types.Evidence{Server: server, Status: types.StatusAvailable, Detail: detail + " matched " + indicator, Matched: indicator, Raw: result}
 				}
 
-				// SECOND: Check for available indicators
-				// Only return true if we have explicit "available" signal
-				if isAvailableFromWHOIS(resultLower) {
-					return true, nil
+				// Check for unavailable indicators (check both original and lowercase)
+				indicator := unavailableIndicator(result)
+				if indicator == "" {
+					indicator = unavailableIndicator(resultLower)
 				}
-
-				// THIRD: Check for unavailable indicators (check both original and lowercase)
-				if isUnavailableFromWHOIS(result) || isUnavailableFromWHOIS(resultLower) {
-					return false, nil
+				if indicator != "" {
+					return types.Evidence{Server: server, Signature: "WHOIS", Status: types.StatusRegistered, Conclusive: true, Detail: detail + " matched " + indicator, Matched: indicator, Raw: result, Registration: registration}
 				}
 				break // Move to next server if result is unclear
 			}
 
+			if err != nil {
+				serverFailed(ctx, server, err)
+				if ServerCooldown(server) > 0 {
+					break // The server kept failing and is paused now
+				}
+			}
+
 			// If there are still retry attempts, use exponential backoff
 			if i < maxRetries-1 {
 				// Calculate exponential delay: baseDelay * 2^i
 				delay := baseDelay * time.Duration(1<<i)
-				time.Sleep(delay)
+				if err := sleepContext(ctx, delay); err != nil {
+					return types.Evidence{Err: err}
+				}
 			}
 		}
-		if server != "" {
-			time.Sleep(1 * time.Second) // Delay before trying next server
-		}
 	}
 
-	// CRITICAL CHANGE: Conservative approach to prevent false positives
-	// Default to UNAVAILABLE if no clear indication
-	// Better to miss a potentially available domain than to report a registered one as available
+	// No clear answer from any server. This is reported as unknown rather than
+	// registered so that the domain can be re-queued instead of silently lost.
+	if pausedServer != "" {
+		return types.Evidence{
+			Server: pausedServer,
+			Status: types.StatusRateLimited,
+			Detail: fmt.Sprintf("WHOIS %s paused for %s", pausedServer, pausedFor.Round(time.Second)),
+		}
+	}
 	if !foundAnyResult {
-		// No WHOIS data could be retrieved - assume domain is NOT available
-		return false, nil
+		return types.Evidence{Err: fmt.Errorf("no WHOIS data could be retrieved")}
 	}
-
-	// WHOIS data was retrieved but couldn't determine status
-	// Apply conservative approach: assume NOT available to prevent false positives
-	return false, nil
+	return types.Evidence{Detail: "unclear WHOIS response", Raw: lastRaw}
 }
 
-func isAvailableFromWHOIS(result string) bool {
+// availableIndicator returns the phrase that marks a WHOIS answer as not
+// registered, or "" if there is none
+func availableIndicator(result string) string {
 	// Most common patterns first for early return
-	if strings.Contains(result, "status: free") ||
-		strings.Contains(result, "not found") ||
-		strings.Contains(result, "no match") ||
-		strings.Contains(result, "status: available") ||
-		strings.Contains(result, "no data found") ||
-		strings.Contains(result, "is available") {
-		return true
+	for _, indicator := range []string{"status: free", "not found", "no match", "status: available", "no data found", "is available"} {
+		if strings.Contains(result, indicator) {
+			return indicator
+		}
 	}
 
 	// Less common patterns
-	initIndicatorMaps()
-	for indicator := range availableIndicatorsMap {
+	for _, indicator := range availableIndicators {
 		if strings.Contains(result, indicator) {
-			return true
+			return indicator
 		}
 	}
 
-	return false
+	return ""
 }
 
-func isUnavailableFromWHOIS(result string) bool {
+// unavailableIndicator returns the phrase that marks a WHOIS answer as
+// registered, or "" if there is none
+func unavailableIndicator(result string) string {
 	// Most common patterns first for early return
-	if strings.Contains(result, "registrar:") ||
-		strings.Contains(result, "name server:") ||
-		strings.Contains(result, "nserver:") ||
-		strings.Contains(result, "creation date:") ||
-		strings.Contains(result, "status: connect") ||
-		strings.Contains(result, "Nserver:") ||
-		strings.Contains(result, "Changed:") {
-		return true
+	for _, indicator := range []string{"registrar:", "name server:", "nserver:", "creation date:", "status: connect", "Nserver:", "Changed:"} {
+		if strings.Contains(result, indicator) {
+			return indicator
+		}
 	}
 
 	// Less common patterns
-	initIndicatorMaps()
-	for indicator := range unavailableIndicatorsMap {
+	for _, indicator := range unavailableIndicators {
 		if strings.Contains(result, indicator) {
-			return true
+			return indicator
 		}
 	}
 
+	return ""
+}
+
+func isRateLimited(result string) bool {
+	for _, indicator := range rateLimitIndicators {
+		if strings.Contains(result, indicator) {
+			return true
+		}
+	}
 	return false
 }
 
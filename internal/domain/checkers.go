@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/reserved"
+	"domain_scanner/internal/types"
+)
+
+func init() {
+	RegisterChecker(reservedChecker{})
+	RegisterChecker(authDNSChecker{})
+	RegisterChecker(dnsChecker{name: "DNS_NS"})
+	RegisterChecker(dnsChecker{name: "DNS_SOA"})
+	RegisterChecker(dnsChecker{name: "DNS_A"})
+	RegisterChecker(dnsChecker{name: "DNS_MX"})
+	RegisterChecker(registrationChecker{})
+	RegisterChecker(sslChecker{})
+}
+
+// reservedChecker applies the local pattern and TLD reservation rules
+type reservedChecker struct{}
+
+func (reservedChecker) Name() string { return "RESERVED" }
+func (reservedChecker) Cost() int    { return 0 }
+
+func (reservedChecker) Check(ctx context.Context, domain string) types.Evidence {
+	if reserved.IsReservedDomain(domain) {
+		return types.Evidence{
+			Signature:  "RESERVED",
+			Status:     types.StatusReserved,
+			Conclusive: true, // No need to hit the network for reserved names
+			Detail:     "matched local reservation rules",
+		}
+	}
+	return types.Evidence{}
+}
+
+// dnsChecker looks up one DNS record type through the configured resolver
+type dnsChecker struct {
+	name string
+}
+
+func (c dnsChecker) Name() string { return c.name }
+func (dnsChecker) Cost() int      { return 1 }
+
+func (c dnsChecker) Check(ctx context.Context, domain string) types.Evidence {
+	if wildcarded(c.name, domain) {
+		return types.Evidence{Detail: "skipped, wildcard DNS answers for every name"}
+	}
+
+	var answers []string // Records in presentation format
+	var err error
+	lookupCtx, cancel := lookupContext(ctx)
+	defer cancel()
+
+	switch c.name {
+	case "DNS_NS":
+		var records []*net.NS
+		records, err = resolver().LookupNS(lookupCtx, domain)
+		for _, record := range records {
+			answers = append(answers, record.Host)
+		}
+	case "DNS_SOA":
+		answers, err = lookupSOA(ctx, domain)
+	case "DNS_A":
+		var records []net.IPAddr
+		records, err = resolver().LookupIPAddr(lookupCtx, domain)
+		for _, record := range records {
+			answers = append(answers, record.String())
+		}
+	case "DNS_MX":
+		var records []*net.MX
+		records, err = resolver().LookupMX(lookupCtx, domain)
+		for _, record := range records {
+			answers = append(answers, fmt.Sprintf("%d %s", record.Pref, record.Host))
+		}
+	}
+
+	if err == nil && len(answers) > 0 {
+		return types.Evidence{
+			Signature: c.name,
+			Status:    types.StatusRegistered,
+			Detail:    fmt.Sprintf("%d record(s)", len(answers)),
+			Matched:   strings.TrimPrefix(c.name, "DNS_") + " record",
+			Raw:       strings.Join(answers, "\n"),
+		}
+	}
+	return types.Evidence{Err: err}
+}
+
+// authDNSChecker asks the TLD's authoritative name servers for the domain's
+// delegation, bypassing resolver caches and NXDOMAIN rewriting. Unlike the
+// resolver-based checks it tells NXDOMAIN apart from failures: a delegation
+// means registered and errors leave the domain unknown. NXDOMAIN is only a
+// hint that the name is available, since registered domains on hold are
+// removed from the zone too: it adds to the confidence of an AVAILABLE
+// verdict from the registry but never decides one, see nxdomainHint.
+type authDNSChecker struct{}
+
+func (authDNSChecker) Name() string { return "DNS_AUTH" }
+func (authDNSChecker) Cost() int    { return 1 }
+
+func (authDNSChecker) Check(ctx context.Context, domain string) types.Evidence {
+	answer, err := queryDelegation(ctx, domain)
+	if err != nil {
+		return types.Evidence{Err: err}
+	}
+
+	switch {
+	case answer.NXDomain:
+		return types.Evidence{Server: answer.Server, Detail: "NXDOMAIN from " + answer.Server, Matched: "NXDOMAIN", Raw: "NXDOMAIN"}
+	case len(answer.Nameservers) > 0:
+		return types.Evidence{
+			Server:    answer.Server,
+			Signature: "DNS_AUTH",
+			Status:    types.StatusRegistered,
+			Detail:    "delegated to " + strings.Join(answer.Nameservers, ", "),
+			Matched:   "NS delegation",
+			Raw:       strings.Join(answer.Nameservers, "\n"),
+		}
+	default:
+		return types.Evidence{Server: answer.Server, Detail: "NOERROR without delegation from " + answer.Server, Raw: "NOERROR"}
+	}
+}
+
+// registrationChecker asks the registry, over RDAP where available and WHOIS otherwise
+type registrationChecker struct{}
+
+func (registrationChecker) Name() string { return "WHOIS" }
+func (registrationChecker) Cost() int    { return 5 }
+
+func (registrationChecker) Check(ctx context.Context, domain string) types.Evidence {
+	rdapResult, err := QueryRDAP(ctx, domain)
+	if err != nil {
+		return checkWHOIS(ctx, domain)
+	}
+	return rdapEvidence(rdapResult)
+}
+
+// rdapEvidence turns an RDAP answer into evidence
+func rdapEvidence(rdapResult *RDAPResult) types.Evidence {
+	evidence := types.Evidence{
+		Server:  rdapResult.Server,
+		Detail:  fmt.Sprintf("RDAP %s: HTTP %d", rdapResult.Server, rdapResult.StatusCode),
+		Matched: fmt.Sprintf("HTTP %d", rdapResult.StatusCode),
+		Raw:     rdapResult.Raw,
+	}
+	if rdapResult.NotFound() {
+		evidence.Status = types.StatusAvailable
+		return evidence
+	}
+
+	evidence.Signature = rdapSignature(rdapResult)
+	evidence.Registration = rdapResult.Registration
+	evidence.Status = types.StatusRegistered
+	if rdapResult.Reserved() {
+		evidence.Status = types.StatusReserved
+	}
+	evidence.Conclusive = true
+	if len(rdapResult.Status) > 0 {
+		evidence.Detail += " status: " + strings.Join(rdapResult.Status, ", ")
+	}
+	return evidence
+}
+
+// nxdomainHint reports whether e is an NXDOMAIN from the TLD's name servers,
+// which supports an AVAILABLE verdict without deciding it
+func nxdomainHint(e types.Evidence) bool {
+	return strings.TrimPrefix(e.Checker, "VERIFY_") == "DNS_AUTH" && e.Status == types.StatusUnknown && e.Matched == "NXDOMAIN"
+}
+
+// sslChecker reports domains serving a TLS certificate on port 443
+type sslChecker struct{}
+
+func (sslChecker) Name() string { return "SSL" }
+func (sslChecker) Cost() int    { return 3 }
+
+func (sslChecker) Check(ctx context.Context, domain string) types.Evidence {
+	if wildcarded("SSL", domain) {
+		return types.Evidence{Detail: "skipped, wildcard DNS answers for every name"}
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 5 * time.Second, Resolver: resolver()},
+		Config:    &tls.Config{InsecureSkipVerify: true},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", domain+":443")
+	if err != nil {
+		return types.Evidence{Err: err}
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	if len(state.PeerCertificates) > 0 {
+		return types.Evidence{
+			Signature: "SSL",
+			Status:    types.StatusRegistered,
+			Detail:    "certificate subject: " + state.PeerCertificates[0].Subject.CommonName,
+		}
+	}
+	return types.Evidence{}
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"domain_scanner/internal/types"
+)
+
+// Weights of single pieces of evidence, i.e. the probability that the signal
+// alone is right. Independent signals add up as 1 - Π(1 - weight).
+var (
+	// Weights of signs that the name is free
+	availableWeights = map[string]float64{
+		"RDAP":     0.6,  // HTTP 404 from the registry's RDAP service
+		"WHOIS":    0.45, // An explicit "not registered" phrase
+		"DNS_AUTH": 0.35, // NXDOMAIN from the TLD's name servers
+	}
+	// Phrases that also turn up in disclaimers and referral notes, so a WHOIS
+	// answer matching only these is weak evidence
+	looseAvailableIndicators = map[string]bool{
+		"not found":              true,
+		"no match":               true,
+		"not registered":         true,
+		"available domain":       true,
+		"domain available":       true,
+		"free domain":            true,
+		"can be registered":      true,
+		"available for purchase": true,
+	}
+	// Weights of the absence of records, which also happens for registered
+	// domains without DNS
+	absenceWeights = map[string]float64{
+		"DNS_NS":  0.1,
+		"DNS_SOA": 0.1,
+		"DNS_A":   0.05,
+		"DNS_MX":  0.05,
+		"SSL":     0.05,
+	}
+
+	// Weights of signs that the name is taken
+	takenWeights = map[string]float64{
+		"RESERVED": 0.9,
+		"RDAP":     0.9,
+		"WHOIS":    0.9,
+		"DNS_AUTH": 0.8,
+		"DNS_NS":   0.7,
+		"DNS_SOA":  0.7,
+		"DNS_A":    0.4,
+		"DNS_MX":   0.4,
+		"SSL":      0.3,
+	}
+)
+
+// confidence scores how strongly the evidence supports the verdict, from 0
+// (no support) to 1. A single WHOIS "no match" scores far lower than an
+// NXDOMAIN from the TLD plus an RDAP 404 plus no DNS records at all.
+func confidence(evidence []types.Evidence, status types.Status) float64 {
+	if !status.Conclusive() {
+		return 0
+	}
+
+	doubt := 1.0
+	for _, e := range evidence {
+		doubt *= 1 - evidenceWeight(e, status)
+	}
+	return 1 - doubt
+}
+
+// evidenceWeight is the weight of one piece of evidence for the verdict
+func evidenceWeight(e types.Evidence, status types.Status) float64 {
+	// Verification checks weigh like the checks they repeat
+	checker := strings.TrimPrefix(e.Checker, "VERIFY_")
+	if checker == "WHOIS" && strings.HasPrefix(e.Detail, "RDAP ") {
+		checker = "RDAP"
+	}
+
+	if status == types.StatusAvailable {
+		switch {
+		case nxdomainHint(e):
+			return availableWeights["DNS_AUTH"]
+		case e.Status == types.StatusAvailable && checker == "WHOIS" && looseAvailableIndicators[e.Matched]:
+			return availableWeights["WHOIS"] / 2
+		case e.Status == types.StatusAvailable:
+			return availableWeights[checker]
+		case e.Status == types.StatusUnknown && absent(e):
+			return absenceWeights[checker]
+		}
+		return 0
+	}
+
+	if e.Status == status || (status.Taken() && e.Status.Taken()) {
+		return takenWeights[checker]
+	}
+	return 0
+}
+
+// absent reports whether the evidence shows that a record does not exist,
+// as opposed to a failed or skipped check
+func absent(e types.Evidence) bool {
+	if e.Err == nil {
+		// Checked without finding anything; skipped checks only leave a Detail
+		return e.Detail == ""
+	}
+	if errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(e.Err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	// A TLS dial that was refused or found no address
+	return e.Checker == "SSL"
+}
@@ -0,0 +1,257 @@
+package domain
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsDialer opens a connection to a DNS server for the given network
+// ("udp" or "tcp"). Connections that are not packet connections are treated
+// as streams of length-prefixed messages.
+type dnsDialer func(ctx context.Context, network string) (net.Conn, error)
+
+// directDialer connects straight to server (host:port)
+func directDialer(server string) dnsDialer {
+	return func(ctx context.Context, network string) (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: dnsTimeout}
+		return dialer.DialContext(ctx, network, server)
+	}
+}
+
+// dnsQuery asks a DNS server a single question. recursive sets the RD bit,
+// which must be off when talking to authoritative servers. Truncated UDP
+// answers are retried over TCP.
+func dnsQuery(ctx context.Context, dial dnsDialer, name string, qtype dnsmessage.Type, recursive bool) (*dnsmessage.Message, error) {
+	fqdn, err := dnsmessage.NewName(dnsFQDN(name))
+	if err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Intn(1 << 16)), RecursionDesired: recursive},
+		Questions: []dnsmessage.Question{{Name: fqdn, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+
+	response, err := dnsExchange(ctx, dial, "udp", packed, query.ID)
+	if err == nil && response.Truncated {
+		response, err = dnsExchange(ctx, dial, "tcp", packed, query.ID)
+	}
+	return response, err
+}
+
+func dnsExchange(ctx context.Context, dial dnsDialer, network string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	conn, err := dial(ctx, network)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buffer := make([]byte, 65535)
+	if _, ok := conn.(net.PacketConn); !ok {
+		// Streams prefix every message with its length
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		if _, err := conn.Write(append(framed, packed...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, buffer[:2]); err != nil {
+			return nil, err
+		}
+		length := binary.BigEndian.Uint16(buffer[:2])
+		if _, err := io.ReadFull(conn, buffer[:length]); err != nil {
+			return nil, err
+		}
+		return parseDNSResponse(buffer[:length], id)
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		response, err := parseDNSResponse(buffer[:n], id)
+		if errors.Is(err, errDNSIDMismatch) {
+			continue // A late answer to an earlier query
+		}
+		return response, err
+	}
+}
+
+var errDNSIDMismatch = errors.New("DNS response ID mismatch")
+
+func parseDNSResponse(data []byte, id uint16) (*dnsmessage.Message, error) {
+	var response dnsmessage.Message
+	if err := response.Unpack(data); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	if response.ID != id {
+		return nil, errDNSIDMismatch
+	}
+	return &response, nil
+}
+
+// dnsFQDN returns the lowercased, dot-terminated form of name
+func dnsFQDN(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// lookupSOA returns the SOA records at the apex of domain, which only exist
+// when the domain is delegated to working name servers
+func lookupSOA(ctx context.Context, domain string) ([]string, error) {
+	fqdn := dnsFQDN(domain)
+	var lastErr error
+	for range recursiveUpstreams() {
+		server := nextUpstream()
+		response, err := dnsQuery(ctx, server.dial, domain, dnsmessage.TypeSOA, true)
+		if err != nil {
+			lastErr = fmt.Errorf("DNS query to %s failed: %w", server, err)
+			continue
+		}
+
+		switch response.RCode {
+		case dnsmessage.RCodeSuccess:
+			var records []string
+			for _, answer := range response.Answers {
+				if soa, ok := answer.Body.(*dnsmessage.SOAResource); ok && strings.EqualFold(answer.Header.Name.String(), fqdn) {
+					records = append(records, fmt.Sprintf("%s %s %d", soa.NS, soa.MBox, soa.Serial))
+				}
+			}
+			return records, nil
+		case dnsmessage.RCodeNameError:
+			return nil, &net.DNSError{Err: "no such host", Name: domain, Server: server.String(), IsNotFound: true}
+		default:
+			lastErr = fmt.Errorf("DNS server %s answered %s", server, response.RCode)
+		}
+	}
+	return nil, lastErr
+}
+
+var (
+	// Addresses (host:port) of the authoritative name servers per TLD,
+	// discovered through the configured resolver on first use
+	tldNameservers   = make(map[string][]string)
+	tldNameserversMu sync.RWMutex
+)
+
+// SetTLDNameservers overrides the authoritative name servers (host:port)
+// queried for a TLD, e.g. to point the DNS_AUTH checker at a local server
+func SetTLDNameservers(tld string, servers ...string) {
+	tldNameserversMu.Lock()
+	defer tldNameserversMu.Unlock()
+	tldNameservers[strings.ToLower(strings.Trim(tld, "."))] = servers
+}
+
+// tldNameserversFor returns the authoritative name servers of the domain's TLD
+func tldNameserversFor(ctx context.Context, domain string) ([]string, error) {
+	tld := tldOf(domain)
+
+	tldNameserversMu.RLock()
+	servers, ok := tldNameservers[tld]
+	tldNameserversMu.RUnlock()
+	if ok {
+		return servers, nil
+	}
+
+	lookupCtx, cancel := lookupContext(ctx)
+	hosts, err := resolver().LookupNS(lookupCtx, tld+".")
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find name servers of .%s: %w", tld, err)
+	}
+	for _, host := range hosts {
+		lookupCtx, cancel := lookupContext(ctx)
+		addresses, err := resolver().LookupHost(lookupCtx, host.Host)
+		cancel()
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			servers = append(servers, net.JoinHostPort(address, "53"))
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no reachable name server for .%s", tld)
+	}
+
+	tldNameserversMu.Lock()
+	tldNameservers[tld] = servers
+	tldNameserversMu.Unlock()
+	return servers, nil
+}
+
+// delegationAnswer is what a TLD name server says about a domain
+type delegationAnswer struct {
+	Server      string
+	NXDomain    bool
+	Nameservers []string // The delegation, empty for NOERROR without NS records
+}
+
+// queryDelegation asks the TLD's authoritative servers for the NS delegation
+// of domain, trying the next server on errors and unusable answers
+func queryDelegation(ctx context.Context, domain string) (*delegationAnswer, error) {
+	servers, err := tldNameserversFor(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	fqdn := dnsFQDN(domain)
+	var lastErr error
+	for _, server := range servers {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		response, err := dnsQuery(ctx, directDialer(server), domain, dnsmessage.TypeNS, false)
+		if err != nil {
+			lastErr = fmt.Errorf("DNS query to %s failed: %w", server, err)
+			continue
+		}
+
+		switch response.RCode {
+		case dnsmessage.RCodeNameError:
+			return &delegationAnswer{Server: server, NXDomain: true}, nil
+		case dnsmessage.RCodeSuccess:
+			answer := &delegationAnswer{Server: server}
+			// A referral lists the delegation in the authority section; a
+			// server that also hosts the child zone answers it directly
+			for _, records := range [][]dnsmessage.Resource{response.Answers, response.Authorities} {
+				for _, record := range records {
+					ns, ok := record.Body.(*dnsmessage.NSResource)
+					if ok && strings.EqualFold(record.Header.Name.String(), fqdn) {
+						answer.Nameservers = append(answer.Nameservers, strings.TrimSuffix(ns.NS.String(), "."))
+					}
+				}
+			}
+			return answer, nil
+		default:
+			lastErr = fmt.Errorf("DNS server %s answered %s", server, response.RCode)
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,242 @@
+package domain
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"domain_scanner/internal/types"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeTLDServer is an in-process authoritative server for the .test TLD,
+// answering over UDP and TCP on the same port
+type fakeTLDServer struct {
+	addr       string
+	udp        net.PacketConn
+	tcp        net.Listener
+	tcpQueries atomic.Int32
+}
+
+// Names the fake server knows about
+const (
+	fakeFree      = "free.test"      // NXDOMAIN
+	fakeTaken     = "taken.test"     // Referral with the delegation in the authority section
+	fakeTruncated = "truncated.test" // Truncated over UDP, referral over TCP
+	fakeServfail  = "servfail.test"  // SERVFAIL
+	fakeRefused   = "refused.test"   // REFUSED
+	fakeSilent    = "silent.test"    // No answer at all
+)
+
+func startFakeTLDServer(t *testing.T) *fakeTLDServer {
+	t.Helper()
+	var s *fakeTLDServer
+	for attempt := 0; attempt < 10 && s == nil; attempt++ {
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen udp: %v", err)
+		}
+		tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+		if err != nil {
+			udp.Close() // Port taken over TCP, try another one
+			continue
+		}
+		s = &fakeTLDServer{addr: udp.LocalAddr().String(), udp: udp, tcp: tcp}
+	}
+	if s == nil {
+		t.Fatal("no free port for UDP and TCP")
+	}
+	t.Cleanup(func() {
+		s.udp.Close()
+		s.tcp.Close()
+	})
+
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+func (s *fakeTLDServer) serveUDP() {
+	buffer := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if answer := s.answer(buffer[:n], false); answer != nil {
+			s.udp.WriteTo(answer, addr)
+		}
+	}
+}
+
+func (s *fakeTLDServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			s.tcpQueries.Add(1)
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			if answer := s.answer(query, true); answer != nil {
+				conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(answer))), answer...))
+			}
+		}()
+	}
+}
+
+// answer builds the response to a packed query, or nil to stay silent
+func (s *fakeTLDServer) answer(packed []byte, overTCP bool) []byte {
+	var query dnsmessage.Message
+	if err := query.Unpack(packed); err != nil || len(query.Questions) != 1 {
+		return nil
+	}
+	question := query.Questions[0]
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true},
+		Questions: query.Questions,
+	}
+	referral := []dnsmessage.Resource{{
+		Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET, TTL: 3600},
+		Body:   &dnsmessage.NSResource{NS: dnsmessage.MustNewName("ns1.example.net.")},
+	}}
+
+	switch question.Name.String() {
+	case fakeFree + ".":
+		response.Authoritative = true
+		response.RCode = dnsmessage.RCodeNameError
+	case fakeTaken + ".":
+		response.Authorities = referral
+	case fakeTruncated + ".":
+		if overTCP {
+			response.Authorities = referral
+		} else {
+			response.Truncated = true
+		}
+	case fakeServfail + ".":
+		response.RCode = dnsmessage.RCodeServerFailure
+	case fakeRefused + ".":
+		response.RCode = dnsmessage.RCodeRefused
+	default:
+		return nil
+	}
+	answer, err := response.Pack()
+	if err != nil {
+		return nil
+	}
+	return answer
+}
+
+// useFakeTLDServer points DNS_AUTH at a fake server for .test and shortens
+// the query timeout for the silent case
+func useFakeTLDServer(t *testing.T) *fakeTLDServer {
+	s := startFakeTLDServer(t)
+	SetTLDNameservers("test", s.addr)
+	timeout := dnsTimeout
+	dnsTimeout = 300 * time.Millisecond
+	t.Cleanup(func() {
+		dnsTimeout = timeout
+		tldNameserversMu.Lock()
+		delete(tldNameservers, "test")
+		tldNameserversMu.Unlock()
+	})
+	return s
+}
+
+func TestAuthDNSChecker(t *testing.T) {
+	s := useFakeTLDServer(t)
+
+	tests := []struct {
+		domain    string
+		status    types.Status
+		hint      bool // NXDOMAIN hint
+		wantError bool
+	}{
+		{domain: fakeFree, status: types.StatusUnknown, hint: true},
+		{domain: fakeTaken, status: types.StatusRegistered},
+		{domain: fakeTruncated, status: types.StatusRegistered},
+		{domain: fakeServfail, status: types.StatusUnknown, wantError: true},
+		{domain: fakeRefused, status: types.StatusUnknown, wantError: true},
+		{domain: fakeSilent, status: types.StatusUnknown, wantError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			e := authDNSChecker{}.Check(context.Background(), test.domain)
+			e.Checker = "DNS_AUTH"
+			if e.Status != test.status {
+				t.Errorf("status = %s, want %s (detail %q, error %v)", e.Status, test.status, e.Detail, e.Err)
+			}
+			if got := nxdomainHint(e); got != test.hint {
+				t.Errorf("NXDOMAIN hint = %v, want %v", got, test.hint)
+			}
+			if (e.Err != nil) != test.wantError {
+				t.Errorf("error = %v, want error: %v", e.Err, test.wantError)
+			}
+			if e.Conclusive {
+				t.Error("DNS_AUTH evidence should never be conclusive")
+			}
+		})
+	}
+
+	if s.tcpQueries.Load() == 0 {
+		t.Error("the truncated answer was not retried over TCP")
+	}
+}
+
+// registryFailure is a fake WHOIS checker that cannot reach the registry
+type registryFailure struct{}
+
+func (registryFailure) Name() string { return "WHOIS" }
+func (registryFailure) Cost() int    { return 2 }
+func (registryFailure) Check(ctx context.Context, domain string) types.Evidence {
+	return types.Evidence{Err: errors.New("connection refused")}
+}
+
+// registryNotFound is a fake WHOIS checker whose registry has no record
+type registryNotFound struct{}
+
+func (registryNotFound) Name() string { return "WHOIS" }
+func (registryNotFound) Cost() int    { return 2 }
+func (registryNotFound) Check(ctx context.Context, domain string) types.Evidence {
+	return types.Evidence{Status: types.StatusAvailable, Matched: "no match for"}
+}
+
+func TestNXDOMAINDoesNotDecideAvailability(t *testing.T) {
+	useFakeTLDServer(t)
+	auth, _ := LookupChecker("DNS_AUTH")
+
+	// NXDOMAIN alone, or with a failed registry lookup, stays unknown
+	for _, pipeline := range []*Pipeline{
+		NewPipelineFromCheckers(auth),
+		NewPipelineFromCheckers(auth, registryFailure{}),
+	} {
+		result := pipeline.Check(context.Background(), fakeFree)
+		if result.Status != types.StatusUnknown {
+			t.Errorf("%v: status = %s, want UNKNOWN (%s)", pipeline.Names(), result.Status, result.Reason)
+		}
+	}
+
+	// With the registry agreeing, NXDOMAIN adds to the confidence
+	withoutHint := NewPipelineFromCheckers(registryNotFound{}).Check(context.Background(), fakeFree)
+	withHint := NewPipelineFromCheckers(auth, registryNotFound{}).Check(context.Background(), fakeFree)
+	if withHint.Status != types.StatusAvailable {
+		t.Fatalf("status = %s, want AVAILABLE (%s)", withHint.Status, withHint.Reason)
+	}
+	if withHint.Confidence <= withoutHint.Confidence {
+		t.Errorf("confidence with NXDOMAIN = %.2f, want more than %.2f without", withHint.Confidence, withoutHint.Confidence)
+	}
+}
@@ -0,0 +1,327 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+// Checker is a single probe in the availability pipeline
+type Checker interface {
+	// Name is the identifier used to select the checker (e.g. DNS_NS)
+	Name() string
+	// Cost is a rough relative expense of running the check, cheapest first
+	Cost() int
+	// Check probes the domain and reports what it observed
+	Check(ctx context.Context, domain string) types.Evidence
+}
+
+var (
+	checkerRegistry   = make(map[string]Checker)
+	checkerRegistryMu sync.RWMutex
+
+	// DefaultCheckers is the pipeline used when none is configured
+	DefaultCheckers = []string{"RESERVED", "DNS_AUTH", "DNS_NS", "DNS_SOA", "DNS_A", "DNS_MX", "WHOIS", "SSL"}
+
+	// delegationCheckers find the NS delegation or the zone apex of a domain,
+	// which only exist for registered domains
+	delegationCheckers = map[string]bool{"DNS_AUTH": true, "DNS_NS": true, "DNS_SOA": true}
+)
+
+// RegisterChecker makes a checker available to pipelines under its name,
+// replacing any checker previously registered with the same name
+func RegisterChecker(checker Checker) {
+	checkerRegistryMu.Lock()
+	defer checkerRegistryMu.Unlock()
+	checkerRegistry[strings.ToUpper(checker.Name())] = checker
+}
+
+// LookupChecker returns the registered checker with the given name
+func LookupChecker(name string) (Checker, bool) {
+	checkerRegistryMu.RLock()
+	defer checkerRegistryMu.RUnlock()
+	checker, ok := checkerRegistry[strings.ToUpper(strings.TrimSpace(name))]
+	return checker, ok
+}
+
+// RegisteredCheckers returns all registered checkers, cheapest first
+func RegisteredCheckers() []Checker {
+	checkerRegistryMu.RLock()
+	checkers := make([]Checker, 0, len(checkerRegistry))
+	for _, checker := range checkerRegistry {
+		checkers = append(checkers, checker)
+	}
+	checkerRegistryMu.RUnlock()
+
+	sort.Slice(checkers, func(i, j int) bool {
+		if checkers[i].Cost() != checkers[j].Cost() {
+			return checkers[i].Cost() < checkers[j].Cost()
+		}
+		return checkers[i].Name() < checkers[j].Name()
+	})
+	return checkers
+}
+
+// Pipeline runs an ordered list of checkers against each domain
+type Pipeline struct {
+	checkers      []Checker
+	keepDelegated bool // Screen passes delegated domains on to the next stage
+}
+
+// NewPipeline builds a pipeline from checker names, run in the given order
+func NewPipeline(names []string) (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		checker, ok := LookupChecker(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown checker %q", name)
+		}
+		seen[name] = true
+		pipeline.checkers = append(pipeline.checkers, checker)
+	}
+
+	if len(pipeline.checkers) == 0 {
+		return nil, fmt.Errorf("pipeline has no checkers")
+	}
+	return pipeline, nil
+}
+
+// NewPipelineFromCheckers builds a pipeline from checker instances, which
+// need not be registered (e.g. a custom registrar lookup or a fake probe)
+func NewPipelineFromCheckers(checkers ...Checker) *Pipeline {
+	return &Pipeline{checkers: checkers}
+}
+
+// ParsePipeline builds a pipeline from a comma-separated checker list,
+// leaving out any checker named in the comma-separated skip list
+func ParsePipeline(checks, skip string) (*Pipeline, error) {
+	names := DefaultCheckers
+	if strings.TrimSpace(checks) != "" {
+		names = strings.Split(checks, ",")
+	}
+
+	skipped := make(map[string]bool)
+	for _, name := range strings.Split(skip, ",") {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name != "" {
+			if _, ok := LookupChecker(name); !ok {
+				return nil, fmt.Errorf("unknown checker %q", name)
+			}
+			skipped[name] = true
+		}
+	}
+
+	var selected []string
+	for _, name := range names {
+		if !skipped[strings.ToUpper(strings.TrimSpace(name))] {
+			selected = append(selected, name)
+		}
+	}
+	return NewPipeline(selected)
+}
+
+// DefaultPipeline returns a pipeline of DefaultCheckers
+func DefaultPipeline() *Pipeline {
+	pipeline, err := NewPipeline(DefaultCheckers)
+	if err != nil {
+		panic(err)
+	}
+	return pipeline
+}
+
+// Names returns the checker names in pipeline order
+func (p *Pipeline) Names() []string {
+	names := make([]string, len(p.checkers))
+	for i, checker := range p.checkers {
+		names[i] = checker.Name()
+	}
+	return names
+}
+
+// Signature identifies the set of checkers regardless of their order, e.g.
+// to tell the cached verdicts of different pipelines apart
+func (p *Pipeline) Signature() string {
+	names := p.Names()
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// Run executes the checkers in order, stopping early once a checker
+// reports conclusive evidence
+func (p *Pipeline) Run(ctx context.Context, domain string) []types.Evidence {
+	var evidence []types.Evidence
+	for _, checker := range p.checkers {
+		if ctx.Err() != nil {
+			break
+		}
+		started := time.Now()
+		e := checker.Check(ctx, domain)
+		e.Checker = checker.Name()
+		e.Latency = time.Since(started)
+		evidence = append(evidence, e)
+		if e.Conclusive {
+			break
+		}
+	}
+	return evidence
+}
+
+// Check runs every checker at most once and derives both the verdict and the
+// signature list from that single pass of evidence
+func (p *Pipeline) Check(ctx context.Context, domain string) types.DomainResult {
+	started := time.Now()
+	return newResult(domain, p.Run(ctx, domain), started, time.Since(started))
+}
+
+// Split divides the pipeline into the checkers costing at most maxCost and
+// the rest, both keeping their order. Either part is nil if it would be empty.
+func (p *Pipeline) Split(maxCost int) (cheap, expensive *Pipeline) {
+	cheap, expensive = &Pipeline{}, &Pipeline{}
+	for _, checker := range p.checkers {
+		if checker.Cost() <= maxCost {
+			cheap.checkers = append(cheap.checkers, checker)
+		} else {
+			expensive.checkers = append(expensive.checkers, checker)
+		}
+	}
+	if len(cheap.checkers) == 0 {
+		cheap = nil
+	}
+	if len(expensive.checkers) == 0 {
+		expensive = nil
+	}
+	return cheap, expensive
+}
+
+// KeepDelegated makes Screen pass domains with a DNS delegation on to the next
+// stage instead of deciding them, so that their registry data is looked up too
+func (p *Pipeline) KeepDelegated() {
+	p.keepDelegated = true
+}
+
+// Screen runs the pipeline as a prefilter stage. The result is final when
+// the evidence already decides the domain, i.e. a checker was conclusive or
+// the domain has a DNS delegation; otherwise it should be passed on to the
+// next stage with CheckAfter.
+func (p *Pipeline) Screen(ctx context.Context, domain string) (types.DomainResult, bool) {
+	result := p.Check(ctx, domain)
+	for _, e := range result.Evidence {
+		if e.Conclusive || (!p.keepDelegated && delegationCheckers[e.Checker] && e.Status == types.StatusRegistered) {
+			return result, true
+		}
+	}
+	return result, false
+}
+
+// CheckAfter continues the check of a domain screened by an earlier stage,
+// deciding the verdict over the evidence of both stages
+func (p *Pipeline) CheckAfter(ctx context.Context, prior types.DomainResult) types.DomainResult {
+	started := time.Now()
+	evidence := append(prior.Evidence[:len(prior.Evidence):len(prior.Evidence)], p.Run(ctx, prior.Domain)...)
+	result := newResult(prior.Domain, evidence, prior.CheckedAt, prior.Latency+time.Since(started))
+	result.Index = prior.Index
+	return result
+}
+
+// newResult derives the verdict, signatures, deciding server and confidence
+// from evidence
+func newResult(domain string, evidence []types.Evidence, checkedAt time.Time, latency time.Duration) types.DomainResult {
+	status, reason := verdict(evidence)
+	result := types.DomainResult{
+		Domain:     domain,
+		Status:     status,
+		Reason:     reason,
+		Evidence:   evidence,
+		Signatures: signaturesFromEvidence(evidence),
+		Server:     decidingServer(evidence, status),
+		Latency:    latency,
+		CheckedAt:  checkedAt,
+		Confidence: confidence(evidence, status),
+	}
+	for _, e := range evidence {
+		if e.Registration != nil {
+			result.Registration = e.Registration
+			break
+		}
+	}
+	return result
+}
+
+// decidingServer returns the server of the first evidence that supports the verdict
+func decidingServer(evidence []types.Evidence, status types.Status) string {
+	for _, e := range evidence {
+		if e.Status == status && e.Server != "" {
+			return e.Server
+		}
+	}
+	return ""
+}
+
+// statusPriority orders verdicts when checkers disagree. A single sign of
+// registration outweighs any number of "not found" answers, so that a
+// registered domain is never reported as available. A registry that refused
+// to answer outweighs a "not found" from another source, so the domain is
+// re-queued rather than reported available. NXDOMAIN is not a verdict at
+// all, so a failed or unclear registry lookup leaves the domain unknown.
+var statusPriority = []types.Status{
+	types.StatusPremium,
+	types.StatusReserved,
+	types.StatusRegistered,
+	types.StatusRateLimited,
+	types.StatusAvailable,
+}
+
+// verdict combines the evidence of all checkers into a single status
+func verdict(evidence []types.Evidence) (types.Status, string) {
+	for _, status := range statusPriority {
+		var sources []string
+		for _, e := range evidence {
+			if e.Status != status {
+				continue
+			}
+			source := e.Checker
+			if e.Detail != "" {
+				source += " (" + e.Detail + ")"
+			}
+			sources = append(sources, source)
+		}
+		if len(sources) > 0 {
+			return status, strings.Join(sources, "; ")
+		}
+	}
+
+	// Nothing conclusive: explain why, so that the domain can be re-queued
+	var problems []string
+	for _, e := range evidence {
+		if e.Err != nil {
+			problems = append(problems, e.Checker+": "+e.Err.Error())
+		} else if e.Detail != "" {
+			problems = append(problems, e.Checker+": "+e.Detail)
+		}
+	}
+	if len(problems) == 0 {
+		return types.StatusUnknown, "no checker gave a conclusive answer"
+	}
+	return types.StatusUnknown, strings.Join(problems, "; ")
+}
+
+// signaturesFromEvidence collects the signatures of evidence showing the name is taken
+func signaturesFromEvidence(evidence []types.Evidence) []string {
+	var signatures []string
+	for _, e := range evidence {
+		if e.Status.Taken() && e.Signature != "" {
+			signatures = append(signatures, e.Signature)
+		}
+	}
+	return signatures
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"domain_scanner/internal/types"
+)
+
+// countingChecker is a fake probe that records how often it ran per domain
+type countingChecker struct {
+	name string
+	cost int
+	// status answers per domain, unknown for domains not listed
+	status map[string]types.Status
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCountingChecker(name string, cost int, status map[string]types.Status) *countingChecker {
+	return &countingChecker{name: name, cost: cost, status: status, calls: make(map[string]int)}
+}
+
+func (c *countingChecker) Name() string { return c.name }
+func (c *countingChecker) Cost() int    { return c.cost }
+
+func (c *countingChecker) Check(ctx context.Context, domain string) types.Evidence {
+	c.mu.Lock()
+	c.calls[domain]++
+	c.mu.Unlock()
+	return types.Evidence{Status: c.status[domain]}
+}
+
+func (c *countingChecker) Calls(domain string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[domain]
+}
+
+var pipelineTestDomains = []string{"aaa.li", "bbb.li", "ccc.li", "ddd.li"}
+
+func TestPipelineCheckRunsEachProbeOnce(t *testing.T) {
+	checkers := []*countingChecker{
+		newCountingChecker("FAKE_DNS", 1, nil),
+		newCountingChecker("FAKE_WHOIS", 2, map[string]types.Status{"bbb.li": types.StatusRegistered}),
+		newCountingChecker("FAKE_SSL", 3, nil),
+	}
+	pipeline := NewPipelineFromCheckers(checkers[0], checkers[1], checkers[2])
+
+	for _, domain := range pipelineTestDomains {
+		result := pipeline.Check(context.Background(), domain)
+		if len(result.Evidence) != len(checkers) {
+			t.Errorf("%s: got %d pieces of evidence, want %d", domain, len(result.Evidence), len(checkers))
+		}
+	}
+	for _, checker := range checkers {
+		for _, domain := range pipelineTestDomains {
+			if calls := checker.Calls(domain); calls != 1 {
+				t.Errorf("%s ran %d times for %s, want 1", checker.Name(), calls, domain)
+			}
+		}
+	}
+}
+
+func TestPipelineStagedCheckRunsEachProbeOnce(t *testing.T) {
+	// bbb.li is delegated and decided by the prefilter, ccc.li is decided by
+	// a conclusive cheap answer
+	ns := newCountingChecker("DNS_NS", 1, map[string]types.Status{"bbb.li": types.StatusRegistered})
+	reserved := &conclusiveChecker{countingChecker: newCountingChecker("FAKE_RESERVED", 0, map[string]types.Status{"ccc.li": types.StatusReserved})}
+	whois := newCountingChecker("FAKE_WHOIS", 2, map[string]types.Status{"ddd.li": types.StatusAvailable})
+	ssl := newCountingChecker("FAKE_SSL", 3, nil)
+	pipeline := NewPipelineFromCheckers(reserved, ns, whois, ssl)
+
+	prefilter, registry := pipeline.Split(1)
+	if prefilter == nil || registry == nil {
+		t.Fatal("Split(1) should give both stages")
+	}
+
+	decided := map[string]bool{"bbb.li": true, "ccc.li": true}
+	for _, domain := range pipelineTestDomains {
+		result, final := prefilter.Screen(context.Background(), domain)
+		if final != decided[domain] {
+			t.Errorf("%s: Screen final = %v, want %v", domain, final, decided[domain])
+		}
+		if !final {
+			result = registry.CheckAfter(context.Background(), result)
+			if len(result.Evidence) != 4 {
+				t.Errorf("%s: got %d pieces of evidence after both stages, want 4", domain, len(result.Evidence))
+			}
+		}
+	}
+
+	for _, domain := range pipelineTestDomains {
+		// The cheap stage runs once for every domain; ccc.li stops at the
+		// conclusive reservation before DNS_NS
+		want := map[string]int{"FAKE_RESERVED": 1, "DNS_NS": 1, "FAKE_WHOIS": 1, "FAKE_SSL": 1}
+		if domain == "ccc.li" {
+			want["DNS_NS"] = 0
+		}
+		if decided[domain] {
+			want["FAKE_WHOIS"], want["FAKE_SSL"] = 0, 0
+		}
+		for _, checker := range []*countingChecker{reserved.countingChecker, ns, whois, ssl} {
+			if calls := checker.Calls(domain); calls != want[checker.Name()] {
+				t.Errorf("%s ran %d times for %s, want %d", checker.Name(), calls, domain, want[checker.Name()])
+			}
+		}
+	}
+}
+
+// conclusiveChecker marks the answers of a counting checker conclusive when
+// they decide the domain
+type conclusiveChecker struct {
+	*countingChecker
+}
+
+func (c *conclusiveChecker) Check(ctx context.Context, domain string) types.Evidence {
+	e := c.countingChecker.Check(ctx, domain)
+	e.Conclusive = e.Status.Conclusive()
+	return e
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/ratelimit"
+)
+
+var (
+	// queryLimiter paces the queries sent to each WHOIS and RDAP server across
+	// all workers. It is nil (unlimited) until SetRateLimiter is called.
+	queryLimiter *ratelimit.Limiter
+
+	// serverBreakers pauses servers that rate limit us or keep failing. It is
+	// nil (never pause) until SetBreakers is called.
+	serverBreakers *ratelimit.Breakers
+)
+
+// SetRateLimiter installs the per-server limiter used by all registry queries
+func SetRateLimiter(limiter *ratelimit.Limiter) {
+	queryLimiter = limiter
+}
+
+// SetBreakers installs the per-server circuit breakers used by all registry queries
+func SetBreakers(breakers *ratelimit.Breakers) {
+	serverBreakers = breakers
+}
+
+// ServerCooldown returns how long server stays paused by its circuit
+// breaker, zero if it accepts queries
+func ServerCooldown(server string) time.Duration {
+	return serverBreakers.Remaining(endpointOf(server))
+}
+
+// waitForServer blocks until the limiter allows another query to server,
+// which may be a WHOIS host[:port] or an RDAP base URL
+func waitForServer(ctx context.Context, server string) error {
+	return queryLimiter.Wait(ctx, endpointOf(server))
+}
+
+// serverAllowed reports whether the server's breaker lets a query through,
+// and otherwise how long it stays paused
+func serverAllowed(server string) (time.Duration, bool) {
+	return serverBreakers.Allow(endpointOf(server))
+}
+
+// serverAnswered records a usable answer from server
+func serverAnswered(server string) {
+	serverBreakers.Success(endpointOf(server))
+}
+
+// serverRateLimited pauses server right away
+func serverRateLimited(server string) {
+	serverBreakers.Trip(endpointOf(server), "rate limited")
+}
+
+// serverErrored records an answer from server that reports a service error,
+// such as "temporarily unavailable" or "access denied"
+func serverErrored(server string) {
+	serverBreakers.Failure(endpointOf(server), "service error")
+}
+
+// serverFailed records a failed query to server. Errors caused by our own
+// context being canceled say nothing about the server and are ignored.
+func serverFailed(ctx context.Context, server string, err error) {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	reason := "query failed"
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		reason = "timed out"
+	}
+	serverBreakers.Failure(endpointOf(server), reason)
+}
+
+// endpointOf reduces a server address to the host name its rate budget is kept under
+func endpointOf(server string) string {
+	if strings.Contains(server, "://") {
+		if u, err := url.Parse(server); err == nil {
+			return u.Hostname()
+		}
+	}
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return host
+	}
+	return server
+}
@@ -0,0 +1,327 @@
+package domain
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+// embeddedRDAPBootstrap is a trimmed copy of the IANA dns.json bootstrap file
+//
+//go:embed rdap_dns.json
+var embeddedRDAPBootstrap []byte
+
+var (
+	// TLD (without dot) -> RDAP base URLs, loaded from the bootstrap file
+	rdapServers     map[string][]string
+	rdapServersMu   sync.RWMutex
+	rdapServersOnce sync.Once
+
+	rdapClient = &http.Client{Timeout: 10 * time.Second}
+
+	// ErrNoRDAPServer is returned when the TLD does not publish an RDAP service
+	ErrNoRDAPServer = errors.New("no RDAP server for TLD")
+
+	// RDAP status values that mean the name cannot be registered right now.
+	// Hold and inactive (no name servers) are statuses of ordinary
+	// registrations and do not belong here.
+	rdapReservedStatuses = []string{
+		"pending delete",
+		"pending restore",
+		"redemption period",
+	}
+)
+
+// rdapBootstrap mirrors the IANA RDAP bootstrap file format (RFC 9224)
+type rdapBootstrap struct {
+	Version     string       `json:"version"`
+	Publication string       `json:"publication"`
+	Services    [][][]string `json:"services"`
+}
+
+// rdapDomain is the subset of an RDAP domain object we care about
+type rdapDomain struct {
+	LDHName string   `json:"ldhName"`
+	Status  []string `json:"status"`
+	Events  []struct {
+		EventAction string `json:"eventAction"`
+		EventDate   string `json:"eventDate"`
+	} `json:"events"`
+	Entities []struct {
+		Roles      []string          `json:"roles"`
+		VCardArray []json.RawMessage `json:"vcardArray"`
+	} `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	SecureDNS *struct {
+		DelegationSigned bool `json:"delegationSigned"`
+	} `json:"secureDNS"`
+}
+
+// registration converts the domain object into a registration record
+func (d *rdapDomain) registration(server string, events map[string]time.Time) *types.Registration {
+	record := &types.Registration{
+		Created:  eventDate(events, "registration"),
+		Updated:  eventDate(events, "last changed"),
+		Expires:  eventDate(events, "expiration"),
+		Statuses: d.Status,
+		Source:   server,
+	}
+	for _, entity := range d.Entities {
+		for _, role := range entity.Roles {
+			if role == "registrar" && record.Registrar == "" {
+				record.Registrar = vcardName(entity.VCardArray)
+			}
+		}
+	}
+	for _, nameserver := range d.Nameservers {
+		record.Nameservers = append(record.Nameservers, strings.ToLower(strings.TrimSuffix(nameserver.LDHName, ".")))
+	}
+	if d.SecureDNS != nil {
+		record.DNSSEC = "unsigned"
+		if d.SecureDNS.DelegationSigned {
+			record.DNSSEC = "signed"
+		}
+	}
+	return record
+}
+
+// vcardName returns the "fn" property of a jCard (RFC 7095):
+// ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Registrar"]]]
+func vcardName(vcard []json.RawMessage) string {
+	if len(vcard) != 2 {
+		return ""
+	}
+	var properties [][]json.RawMessage
+	if err := json.Unmarshal(vcard[1], &properties); err != nil {
+		return ""
+	}
+	for _, property := range properties {
+		var name, value string
+		if len(property) == 4 && json.Unmarshal(property[0], &name) == nil && name == "fn" &&
+			json.Unmarshal(property[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+// eventDate returns the date of an RDAP event, or nil if the registry did not
+// publish it
+func eventDate(events map[string]time.Time, action string) *time.Time {
+	if t, ok := events[action]; ok {
+		return &t
+	}
+	return nil
+}
+
+// RDAPResult is the machine-readable outcome of an RDAP domain lookup
+type RDAPResult struct {
+	Server       string               // RDAP base URL that answered
+	StatusCode   int                  // HTTP status code (200 registered, 404 not found)
+	Status       []string             // RDAP status array, lowercased
+	Events       map[string]time.Time // eventAction -> eventDate
+	Registration *types.Registration  // Registration record of a registered domain
+	Raw          string               // Response body
+}
+
+// Registered reports whether the registry returned a domain object
+func (r *RDAPResult) Registered() bool {
+	return r.StatusCode == http.StatusOK
+}
+
+// NotFound reports whether the registry has no record of the domain
+func (r *RDAPResult) NotFound() bool {
+	return r.StatusCode == http.StatusNotFound
+}
+
+// Reserved reports whether the status array marks the domain as not registrable
+func (r *RDAPResult) Reserved() bool {
+	for _, status := range r.Status {
+		for _, reservedStatus := range rdapReservedStatuses {
+			if status == reservedStatus {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseRDAPBootstrap converts a bootstrap file into a TLD -> base URL map
+func parseRDAPBootstrap(data []byte) (map[string][]string, error) {
+	var bootstrap rdapBootstrap
+	if err := json.Unmarshal(data, &bootstrap); err != nil {
+		return nil, fmt.Errorf("invalid RDAP bootstrap file: %w", err)
+	}
+
+	servers := make(map[string][]string)
+	for _, service := range bootstrap.Services {
+		if len(service) != 2 {
+			continue
+		}
+		for _, tld := range service[0] {
+			tld = strings.ToLower(strings.TrimPrefix(tld, "."))
+			for _, url := range service[1] {
+				if !strings.HasSuffix(url, "/") {
+					url += "/"
+				}
+				servers[tld] = append(servers[tld], url)
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("RDAP bootstrap file contains no services")
+	}
+	return servers, nil
+}
+
+// initRDAPServers loads the embedded bootstrap file on first use
+func initRDAPServers() {
+	rdapServersOnce.Do(func() {
+		servers, err := parseRDAPBootstrap(embeddedRDAPBootstrap)
+		if err != nil {
+			servers = map[string][]string{}
+		}
+
+		rdapServersMu.Lock()
+		if rdapServers == nil {
+			rdapServers = servers
+		}
+		rdapServersMu.Unlock()
+	})
+}
+
+// LoadRDAPBootstrap replaces the embedded RDAP bootstrap data with an IANA dns.json file from disk
+func LoadRDAPBootstrap(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read RDAP bootstrap file: %w", err)
+	}
+
+	servers, err := parseRDAPBootstrap(data)
+	if err != nil {
+		return err
+	}
+
+	initRDAPServers()
+	rdapServersMu.Lock()
+	rdapServers = servers
+	rdapServersMu.Unlock()
+	return nil
+}
+
+// rdapServersFor returns the RDAP base URLs responsible for the domain's TLD
+func rdapServersFor(domain string) []string {
+	initRDAPServers()
+
+	rdapServersMu.RLock()
+	defer rdapServersMu.RUnlock()
+	return rdapServers[tldOf(domain)]
+}
+
+// QueryRDAP looks the domain up at its registry's RDAP service.
+// Only 200 and 404 answers are returned as results; anything else is an error
+// so that callers can fall back to WHOIS.
+func QueryRDAP(ctx context.Context, domain string) (*RDAPResult, error) {
+	servers := rdapServersFor(domain)
+	if len(servers) == 0 {
+		return nil, ErrNoRDAPServer
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		if cooldown, ok := serverAllowed(server); !ok {
+			lastErr = fmt.Errorf("RDAP server %s paused for %s", server, cooldown.Round(time.Second))
+			continue
+		}
+		result, err := queryRDAPServer(ctx, server, domain)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func queryRDAPServer(ctx context.Context, server, domain string) (*RDAPResult, error) {
+	if err := waitForServer(ctx, server); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"domain/"+strings.ToLower(domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := rdapClient.Do(req)
+	if err != nil {
+		serverFailed(ctx, server, err)
+		return nil, fmt.Errorf("RDAP query to %s failed: %w", server, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		serverRateLimited(server)
+	case resp.StatusCode >= 500:
+		serverFailed(ctx, server, fmt.Errorf("HTTP %d", resp.StatusCode))
+	default:
+		serverAnswered(server)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RDAP response from %s: %w", server, err)
+	}
+	result := &RDAPResult{
+		Server:     server,
+		StatusCode: resp.StatusCode,
+		Events:     make(map[string]time.Time),
+		Raw:        string(body),
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return result, nil
+	case http.StatusOK:
+		var object rdapDomain
+		if err := json.Unmarshal(body, &object); err != nil {
+			return nil, fmt.Errorf("invalid RDAP response from %s: %w", server, err)
+		}
+		for _, status := range object.Status {
+			result.Status = append(result.Status, strings.ToLower(status))
+		}
+		for _, event := range object.Events {
+			if t, err := time.Parse(time.RFC3339, event.EventDate); err == nil {
+				result.Events[strings.ToLower(event.EventAction)] = t
+			}
+		}
+		result.Registration = object.registration(server, result.Events)
+		return result, nil
+	default:
+		return nil, fmt.Errorf("RDAP server %s returned HTTP %d", server, resp.StatusCode)
+	}
+}
+
+// rdapSignature maps an RDAP result to a registration signature, if any
+func rdapSignature(result *RDAPResult) string {
+	if !result.Registered() {
+		return ""
+	}
+	if result.Reserved() {
+		return "RESERVED"
+	}
+	return "RDAP"
+}
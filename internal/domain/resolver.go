@@ -0,0 +1,266 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// upstream is a recursive DNS server: plain DNS (udp), DNS-over-TLS (tls)
+// or DNS-over-HTTPS (https)
+type upstream struct {
+	scheme  string
+	address string // host:port, or the URL for DNS-over-HTTPS
+}
+
+func (u upstream) String() string {
+	if u.scheme == "tls" {
+		return "tls://" + u.address
+	}
+	return u.address
+}
+
+var (
+	// Configured upstreams, used round-robin by every DNS check. Empty means
+	// the system resolver configuration.
+	upstreams      []upstream
+	upstreamNext   atomic.Uint64
+	upstreamsMu    sync.RWMutex
+	dnsResolver    = net.DefaultResolver
+	dnsTimeout     = 5 * time.Second
+	dohClient      = &http.Client{}
+	systemUpstream struct {
+		once sync.Once
+		list []upstream
+	}
+)
+
+// ConfigureResolver makes all DNS checks use the given upstreams in turn
+// instead of the system resolver. Each upstream is one of
+//
+//	8.8.8.8 or 8.8.8.8:53         plain DNS (UDP, TCP for long answers)
+//	tls://dns.google[:853]         DNS-over-TLS
+//	https://dns.google/dns-query   DNS-over-HTTPS
+//
+// timeout bounds each query.
+func ConfigureResolver(specs []string, timeout time.Duration) error {
+	var parsed []upstream
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		u, err := parseUpstream(spec)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, u)
+	}
+
+	if timeout > 0 {
+		dnsTimeout = timeout
+		dohClient.Timeout = timeout
+	}
+
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+	upstreams = parsed
+	if len(parsed) == 0 {
+		dnsResolver = net.DefaultResolver
+		return nil
+	}
+	dnsResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			// The Go resolver dials once per attempt, so retries rotate
+			// through the upstreams
+			return nextUpstream().dial(ctx, network)
+		},
+	}
+	return nil
+}
+
+func parseUpstream(spec string) (upstream, error) {
+	scheme, rest, found := strings.Cut(spec, "://")
+	if !found {
+		scheme, rest = "udp", spec
+	}
+
+	switch strings.ToLower(scheme) {
+	case "udp", "dns":
+		return upstream{scheme: "udp", address: withDefaultPort(rest, "53")}, nil
+	case "tls":
+		return upstream{scheme: "tls", address: withDefaultPort(rest, "853")}, nil
+	case "https", "http":
+		if _, err := url.Parse(spec); err != nil {
+			return upstream{}, fmt.Errorf("invalid DNS-over-HTTPS resolver %q: %w", spec, err)
+		}
+		return upstream{scheme: "https", address: spec}, nil
+	default:
+		return upstream{}, fmt.Errorf("unsupported resolver %q (use host[:port], tls://host[:port] or https://host/path)", spec)
+	}
+}
+
+func withDefaultPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), port)
+}
+
+// resolver returns the resolver all DNS checks go through
+func resolver() *net.Resolver {
+	upstreamsMu.RLock()
+	defer upstreamsMu.RUnlock()
+	return dnsResolver
+}
+
+// lookupContext bounds one resolver lookup by the DNS timeout. Without it the
+// Go resolver applies the timeout and attempts of /etc/resolv.conf (5s, 2
+// attempts by default) instead of -resolver-timeout.
+func lookupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, dnsTimeout)
+}
+
+// recursiveUpstreams returns the configured upstreams, or the name servers
+// of /etc/resolv.conf when none are configured
+func recursiveUpstreams() []upstream {
+	upstreamsMu.RLock()
+	configured := upstreams
+	upstreamsMu.RUnlock()
+	if len(configured) > 0 {
+		return configured
+	}
+
+	systemUpstream.once.Do(func() {
+		file, err := os.Open("/etc/resolv.conf")
+		if err == nil {
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) >= 2 && fields[0] == "nameserver" {
+					systemUpstream.list = append(systemUpstream.list, upstream{scheme: "udp", address: net.JoinHostPort(fields[1], "53")})
+				}
+			}
+		}
+		if len(systemUpstream.list) == 0 {
+			systemUpstream.list = []upstream{{scheme: "udp", address: "127.0.0.1:53"}}
+		}
+	})
+	return systemUpstream.list
+}
+
+// nextUpstream picks the configured upstreams round-robin
+func nextUpstream() upstream {
+	list := recursiveUpstreams()
+	return list[int(upstreamNext.Add(1)-1)%len(list)]
+}
+
+// dial opens a connection to the upstream. Plain DNS connections speak the
+// requested network; DoT and DoH connections are streams, which the Go
+// resolver and dnsQuery frame with a length prefix like DNS over TCP.
+func (u upstream) dial(ctx context.Context, network string) (net.Conn, error) {
+	switch u.scheme {
+	case "tls":
+		host, _, _ := net.SplitHostPort(u.address)
+		dialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: dnsTimeout},
+			Config:    &tls.Config{ServerName: host},
+		}
+		return dialer.DialContext(ctx, "tcp", u.address)
+	case "https":
+		return &dohConn{ctx: ctx, url: u.address}, nil
+	default:
+		dialer := &net.Dialer{Timeout: dnsTimeout}
+		return dialer.DialContext(ctx, network, u.address)
+	}
+}
+
+// dohConn carries length-prefixed DNS messages over DNS-over-HTTPS (RFC 8484):
+// each message written is POSTed to the server and the answer is returned by
+// the following reads
+type dohConn struct {
+	ctx      context.Context
+	url      string
+	deadline time.Time
+	request  bytes.Buffer
+	response bytes.Reader
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.request.Write(b)
+	for c.request.Len() >= 2 {
+		length := int(binary.BigEndian.Uint16(c.request.Bytes()[:2]))
+		if c.request.Len() < 2+length {
+			break
+		}
+		message := make([]byte, length)
+		c.request.Next(2)
+		c.request.Read(message)
+
+		answer, err := c.roundTrip(message)
+		if err != nil {
+			return 0, err
+		}
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(answer)))
+		c.response.Reset(append(framed, answer...))
+	}
+	return len(b), nil
+}
+
+func (c *dohConn) roundTrip(message []byte) ([]byte, error) {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := dohClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server %s returned HTTP %d", c.url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+func (c *dohConn) Read(b []byte) (int, error) { return c.response.Read(b) }
+func (c *dohConn) Close() error               { return nil }
+func (c *dohConn) LocalAddr() net.Addr        { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr       { return dohAddr(c.url) }
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
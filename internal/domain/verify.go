@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+// maxVerifyWHOISServers caps the WHOIS servers asked separately per domain
+const maxVerifyWHOISServers = 2
+
+// Verify re-checks a domain judged available against independent sources:
+// the registry's RDAP service, each authoritative WHOIS server on its own
+// and the TLD's name servers. The domain stays available only if at least
+// two registry sources (RDAP or WHOIS servers) confirm it and no source
+// disagrees; any disagreement makes it unknown. An NXDOMAIN from the name
+// servers only adds to the confidence, as held domains are left out of the
+// zone too. Results that are not available are returned unchanged.
+func Verify(ctx context.Context, prior types.DomainResult) types.DomainResult {
+	if prior.Status != types.StatusAvailable {
+		return prior
+	}
+	started := time.Now()
+
+	var checks []types.Evidence
+	run := func(name string, check func() types.Evidence) {
+		if ctx.Err() != nil {
+			return
+		}
+		checkStarted := time.Now()
+		e := check()
+		e.Checker = name
+		e.Latency = time.Since(checkStarted)
+		checks = append(checks, e)
+	}
+
+	if len(rdapServersFor(prior.Domain)) > 0 {
+		run("VERIFY_RDAP", func() types.Evidence {
+			result, err := QueryRDAP(ctx, prior.Domain)
+			if err != nil {
+				return types.Evidence{Err: err}
+			}
+			return rdapEvidence(result)
+		})
+	}
+	servers := whoisServersFor(ctx, prior.Domain)
+	if len(servers) > maxVerifyWHOISServers {
+		servers = servers[:maxVerifyWHOISServers]
+	}
+	for _, server := range servers {
+		run("VERIFY_WHOIS", func() types.Evidence {
+			return checkWHOISServers(ctx, prior.Domain, []string{server})
+		})
+	}
+	if len(servers) == 0 {
+		run("VERIFY_WHOIS", func() types.Evidence {
+			return types.Evidence{Err: fmt.Errorf("no WHOIS server known for .%s", tldOf(prior.Domain))}
+		})
+	}
+	run("VERIFY_DNS_AUTH", func() types.Evidence {
+		return authDNSChecker{}.Check(ctx, prior.Domain)
+	})
+
+	evidence := append(prior.Evidence[:len(prior.Evidence):len(prior.Evidence)], checks...)
+	result := newResult(prior.Domain, evidence, prior.CheckedAt, prior.Latency+time.Since(started))
+	result.Index = prior.Index
+	result.Verified = true
+	result.Status, result.Reason = verification(checks, prior.Reason)
+	result.Server = decidingServer(checks, result.Status)
+	result.Confidence = confidence(evidence, result.Status)
+	return result
+}
+
+// verification decides the outcome of the verification checks
+func verification(checks []types.Evidence, priorReason string) (types.Status, string) {
+	var confirmed, disagreed, failed []string
+	rateLimited := false
+	for _, e := range checks {
+		source := e.Checker
+		if e.Server != "" {
+			source += " " + e.Server
+		}
+		switch {
+		case nxdomainHint(e):
+			// Counted in the confidence, not as a confirmation
+		case e.Status == types.StatusAvailable:
+			confirmed = append(confirmed, source)
+		case e.Status.Conclusive():
+			disagreed = append(disagreed, fmt.Sprintf("%s says %s", source, e.Status))
+		case e.Status == types.StatusRateLimited:
+			rateLimited = true
+			failed = append(failed, source+" rate limited")
+		case e.Err != nil && !errors.Is(e.Err, ErrNoRDAPServer):
+			failed = append(failed, source+": "+e.Err.Error())
+		}
+	}
+
+	switch {
+	case len(disagreed) > 0:
+		return types.StatusUnknown, "verification disagreed: " + strings.Join(disagreed, "; ")
+	case len(confirmed) >= 2:
+		return types.StatusAvailable, priorReason + "; confirmed by " + strings.Join(confirmed, ", ")
+	case rateLimited:
+		return types.StatusRateLimited, "verification rate limited: " + strings.Join(failed, "; ")
+	default:
+		reason := fmt.Sprintf("verification confirmed by %d registry source(s), 2 RDAP or WHOIS sources needed", len(confirmed))
+		if len(failed) > 0 {
+			reason += ": " + strings.Join(failed, "; ")
+		}
+		return types.StatusUnknown, reason
+	}
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"domain_scanner/internal/types"
+)
+
+func TestVerificationNeedsTwoRegistrySources(t *testing.T) {
+	rdapFree := types.Evidence{Checker: "VERIFY_RDAP", Status: types.StatusAvailable, Server: "https://rdap.example/"}
+	whoisFree := func(server string) types.Evidence {
+		return types.Evidence{Checker: "VERIFY_WHOIS", Status: types.StatusAvailable, Server: server}
+	}
+	whoisFailed := types.Evidence{Checker: "VERIFY_WHOIS", Server: "whois.example", Err: errors.New("connection refused")}
+	nxdomain := types.Evidence{Checker: "VERIFY_DNS_AUTH", Matched: "NXDOMAIN"}
+	delegated := types.Evidence{Checker: "VERIFY_DNS_AUTH", Status: types.StatusRegistered}
+
+	tests := []struct {
+		name   string
+		checks []types.Evidence
+		want   types.Status
+	}{
+		{"RDAP and WHOIS", []types.Evidence{rdapFree, whoisFree("whois.example"), nxdomain}, types.StatusAvailable},
+		{"two WHOIS servers", []types.Evidence{whoisFree("whois1.example"), whoisFree("whois2.example")}, types.StatusAvailable},
+		{"RDAP and NXDOMAIN, WHOIS failed", []types.Evidence{rdapFree, whoisFailed, nxdomain}, types.StatusUnknown},
+		{"WHOIS and NXDOMAIN", []types.Evidence{whoisFree("whois.example"), nxdomain}, types.StatusUnknown},
+		{"delegation disagrees", []types.Evidence{rdapFree, whoisFree("whois.example"), delegated}, types.StatusUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, reason := verification(test.checks, "prior")
+			if status != test.want {
+				t.Errorf("status = %s, want %s (%s)", status, test.want, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package domain
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/likexian/whois"
+)
+
+const (
+	ianaWhoisServer = "whois.iana.org"
+	whoisTimeout    = 30 * time.Second
+)
+
+var (
+	// Authoritative WHOIS servers per TLD, taken from the IANA root zone
+	// database ("whois:" referral of each TLD). TLDs not listed here are
+	// resolved against whois.iana.org on first use.
+	tldWhoisServers = map[string][]string{
+		"com":  {"whois.verisign-grs.com"},
+		"net":  {"whois.verisign-grs.com"},
+		"cc":   {"ccwhois.verisign-grs.com"},
+		"tv":   {"whois.nic.tv"},
+		"org":  {"whois.publicinterestregistry.org"},
+		"info": {"whois.nic.info"},
+		"biz":  {"whois.nic.biz"},
+		"mobi": {"whois.nic.mobi"},
+		"pro":  {"whois.nic.pro"},
+		"xyz":  {"whois.nic.xyz"},
+		"top":  {"whois.nic.top"},
+		"app":  {"whois.nic.google"},
+		"dev":  {"whois.nic.google"},
+		"io":   {"whois.nic.io"},
+		"ai":   {"whois.nic.ai"},
+		"me":   {"whois.nic.me"},
+		"us":   {"whois.nic.us"},
+		"li":   {"whois.nic.li"},
+		"ch":   {"whois.nic.ch"},
+		"de":   {"whois.denic.de"},
+		"at":   {"whois.nic.at"},
+		"cz":   {"whois.nic.cz"},
+		"cx":   {"whois.nic.cx"},
+		"uk":   {"whois.nic.uk"},
+		"eu":   {"whois.eu"},
+		"fr":   {"whois.nic.fr"},
+		"it":   {"whois.nic.it"},
+		"es":   {"whois.nic.es"},
+		"nl":   {"whois.domain-registry.nl"},
+		"be":   {"whois.dns.be"},
+		"se":   {"whois.iis.se"},
+		"nu":   {"whois.iis.nu"},
+		"pl":   {"whois.dns.pl"},
+		"ru":   {"whois.tcinet.ru"},
+		"ca":   {"whois.cira.ca"},
+		"jp":   {"whois.jprs.jp"},
+		"cn":   {"whois.cnnic.cn"},
+	}
+	tldWhoisServersMu sync.RWMutex
+
+	// Thin registries only hold the registrar referral, the full record
+	// lives on the registrar's own WHOIS server
+	thinRegistries = map[string]bool{
+		"com": true,
+		"net": true,
+		"cc":  true,
+		"tv":  true,
+	}
+
+	ianaReferralPattern      = regexp.MustCompile(`(?mi)^whois:\s*(\S+)\s*$`)
+	registrarReferralPattern = regexp.MustCompile(`(?mi)^\s*registrar whois server:\s*(\S+)\s*$`)
+)
+
+// LoadWHOISServers reads a TLD -> WHOIS server override file. Each line holds a
+// TLD followed by one or more servers, separated by spaces or commas:
+//
+//	li whois.nic.li
+//	.com whois.verisign-grs.com:43
+func LoadWHOISServers(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open WHOIS server file: %w", err)
+	}
+	defer file.Close()
+
+	overrides := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		if len(fields) < 2 {
+			return fmt.Errorf("WHOIS server file line %d: expected \"<tld> <server>...\"", lineNumber)
+		}
+		tld := strings.ToLower(strings.TrimPrefix(fields[0], "."))
+		overrides[tld] = fields[1:]
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading WHOIS server file: %w", err)
+	}
+
+	tldWhoisServersMu.Lock()
+	defer tldWhoisServersMu.Unlock()
+	for tld, servers := range overrides {
+		tldWhoisServers[tld] = servers
+	}
+	return nil
+}
+
+// whoisServersFor returns the authoritative WHOIS servers for the domain's TLD,
+// asking IANA for the referral the first time an unlisted TLD is seen
+func whoisServersFor(ctx context.Context, domain string) []string {
+	tld := tldOf(domain)
+
+	tldWhoisServersMu.RLock()
+	servers, ok := tldWhoisServers[tld]
+	tldWhoisServersMu.RUnlock()
+	if ok {
+		return servers
+	}
+
+	servers = nil
+	if err := waitForServer(ctx, ianaWhoisServer); err != nil {
+		return nil
+	}
+	if result, err := newWHOISClient(ctx).Whois(tld, ianaWhoisServer); err == nil {
+		if match := ianaReferralPattern.FindStringSubmatch(result); match != nil {
+			servers = []string{strings.ToLower(match[1])}
+		}
+	} else {
+		// Don't cache lookup failures, IANA may just be unreachable right now
+		return nil
+	}
+
+	// Cache the answer, including "no WHOIS server" for TLDs without one
+	tldWhoisServersMu.Lock()
+	tldWhoisServers[tld] = servers
+	tldWhoisServersMu.Unlock()
+	return servers
+}
+
+// queryWHOIS queries a single server without the library's automatic
+// referral handling, then follows the registrar referral for thin registries
+func queryWHOIS(ctx context.Context, domain, server string) (string, error) {
+	if err := waitForServer(ctx, server); err != nil {
+		return "", err
+	}
+	client := newWHOISClient(ctx)
+	result, err := client.Whois(domain, server)
+	if err != nil || !thinRegistries[tldOf(domain)] {
+		return result, err
+	}
+
+	match := registrarReferralPattern.FindStringSubmatch(result)
+	if match == nil {
+		return result, nil
+	}
+	referral := strings.ToLower(strings.TrimSuffix(match[1], "/"))
+	referral = strings.TrimPrefix(strings.TrimPrefix(referral, "whois://"), "http://")
+	if referral == "" || referral == strings.ToLower(server) {
+		return result, nil
+	}
+
+	// The registry answer already decides the verdict, so a failing
+	// registrar server only costs us the detailed record
+	if err := waitForServer(ctx, referral); err != nil {
+		return result, nil
+	}
+	if registrarResult, err := client.Whois(domain, referral); err == nil {
+		result += "\n" + registrarResult
+	}
+	return result, nil
+}
+
+// newWHOISClient returns a WHOIS client whose connections are closed when ctx is canceled
+func newWHOISClient(ctx context.Context) *whois.Client {
+	dialer := &contextDialer{ctx: ctx, dialer: net.Dialer{Timeout: whoisTimeout}}
+	return whois.NewClient().SetDialer(dialer).SetTimeout(whoisTimeout).SetDisableReferral(true)
+}
+
+// contextDialer adapts net.Dialer to the whois client's context-less Dial
+type contextDialer struct {
+	ctx    context.Context
+	dialer net.Dialer
+}
+
+func (d *contextDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(d.ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	// Unblock pending reads and writes as soon as the context is canceled
+	stop := context.AfterFunc(d.ctx, func() { conn.Close() })
+	return &contextConn{Conn: conn, stop: stop}, nil
+}
+
+type contextConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *contextConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// sleepContext waits for d or until ctx is canceled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tldOf returns the last label of a domain, lowercased and without dots
+func tldOf(domain string) string {
+	parts := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// wildcardProbes is the number of random labels resolved per suffix; a
+// record type counts as wildcarded when most of them resolve
+const wildcardProbes = 3
+
+// Wildcard describes which record types a suffix answers for any name
+type Wildcard struct {
+	Suffix string
+	A      bool
+	MX     bool
+}
+
+// Detected reports whether any wildcard was found
+func (w Wildcard) Detected() bool {
+	return w.A || w.MX
+}
+
+var (
+	wildcards   = make(map[string]Wildcard)
+	wildcardsMu sync.RWMutex
+)
+
+// CalibrateWildcard resolves random labels that are certainly not registered
+// under suffix. If they resolve, the TLD (or the resolver) answers for every
+// name, and DNS_A/DNS_MX evidence for domains under suffix is ignored from
+// then on; so is SSL evidence when A records are wildcarded, since the TLS
+// dial would reach the wildcard address.
+func CalibrateWildcard(ctx context.Context, suffix string) Wildcard {
+	suffix = "." + strings.Trim(strings.ToLower(suffix), ".")
+	result := Wildcard{Suffix: suffix}
+
+	aHits, mxHits := 0, 0
+	for i := 0; i < wildcardProbes; i++ {
+		probe := randomLabel(24) + suffix
+		lookupCtx, cancel := lookupContext(ctx)
+		if addresses, err := resolver().LookupIPAddr(lookupCtx, probe); err == nil && len(addresses) > 0 {
+			aHits++
+		}
+		cancel()
+		lookupCtx, cancel = lookupContext(ctx)
+		if records, err := resolver().LookupMX(lookupCtx, probe); err == nil && len(records) > 0 {
+			mxHits++
+		}
+		cancel()
+	}
+	result.A = aHits*2 > wildcardProbes
+	result.MX = mxHits*2 > wildcardProbes
+
+	wildcardsMu.Lock()
+	wildcards[suffix] = result
+	wildcardsMu.Unlock()
+	return result
+}
+
+// wildcardFor returns the calibration of the longest calibrated suffix of domain
+func wildcardFor(domain string) (Wildcard, bool) {
+	domain = "." + strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	wildcardsMu.RLock()
+	defer wildcardsMu.RUnlock()
+	var best Wildcard
+	found := false
+	for suffix, wildcard := range wildcards {
+		if strings.HasSuffix(domain, suffix) && len(suffix) > len(best.Suffix) {
+			best, found = wildcard, true
+		}
+	}
+	return best, found
+}
+
+// wildcarded reports whether evidence of the named checker is meaningless
+// for domain because its suffix answers for every name
+func wildcarded(checker, domain string) bool {
+	wildcard, ok := wildcardFor(domain)
+	if !ok {
+		return false
+	}
+	switch checker {
+	case "DNS_A", "SSL":
+		return wildcard.A
+	case "DNS_MX":
+		return wildcard.MX
+	}
+	return false
+}
+
+// randomLabel returns a label that is practically never registered
+func randomLabel(length int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	label := make([]byte, length)
+	label[0] = 'z' // Labels must not start with a hyphen or look numeric
+	for i := 1; i < length; i++ {
+		label[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(label)
+}
@@ -0,0 +1,181 @@
+package evidence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+// Record is the decision trace of one domain as stored on disk
+type Record struct {
+	Domain     string       `json:"domain"`
+	Status     types.Status `json:"status"`
+	Confidence float64      `json:"confidence"`
+	Reason     string       `json:"reason,omitempty"`
+	Server     string       `json:"server,omitempty"`
+	CheckedAt  time.Time    `json:"checked_at"`
+	LatencyMs  int64        `json:"latency_ms"`
+	Evidence   []Entry      `json:"evidence"`
+}
+
+// Entry is what one checker observed, with the raw answer it judged
+type Entry struct {
+	Checker    string       `json:"checker"`
+	Status     types.Status `json:"status"`
+	Conclusive bool         `json:"conclusive,omitempty"`
+	Signature  string       `json:"signature,omitempty"`
+	Server     string       `json:"server,omitempty"`
+	Detail     string       `json:"detail,omitempty"`
+	Matched    string       `json:"matched,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	LatencyMs  int64        `json:"latency_ms"`
+	Raw        string       `json:"raw,omitempty"`
+}
+
+// NewRecord converts a domain result into its decision trace
+func NewRecord(result types.DomainResult) Record {
+	record := Record{
+		Domain:     result.Domain,
+		Status:     result.Status,
+		Confidence: result.Confidence,
+		Reason:     result.Reason,
+		Server:     result.Server,
+		CheckedAt:  result.CheckedAt,
+		LatencyMs:  result.Latency.Milliseconds(),
+		Evidence:   make([]Entry, 0, len(result.Evidence)),
+	}
+	for _, e := range result.Evidence {
+		entry := Entry{
+			Checker:    e.Checker,
+			Status:     e.Status,
+			Conclusive: e.Conclusive,
+			Signature:  e.Signature,
+			Server:     e.Server,
+			Detail:     e.Detail,
+			Matched:    e.Matched,
+			LatencyMs:  e.Latency.Milliseconds(),
+			Raw:        e.Raw,
+		}
+		if e.Err != nil {
+			entry.Error = e.Err.Error()
+		}
+		record.Evidence = append(record.Evidence, entry)
+	}
+	return record
+}
+
+// Store keeps one decision trace file per domain in a directory
+type Store struct {
+	dir string
+}
+
+// Open creates the directory if needed and returns a store writing to it
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create evidence directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save writes the decision trace of a result, replacing an earlier one.
+// Results answered from the cache carry no evidence and are skipped, so the
+// trace of the check that produced the cached verdict is kept.
+func (s *Store) Save(result types.DomainResult) error {
+	if result.Cached {
+		return nil
+	}
+	data, err := json.MarshalIndent(NewRecord(result), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := s.path(result.Domain)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write evidence file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads the decision trace of a domain
+func Load(dir, domain string) (*Record, error) {
+	data, err := os.ReadFile((&Store{dir: dir}).path(domain))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no evidence recorded for %s in %s", domain, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read evidence file: %w", err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid evidence file: %w", err)
+	}
+	return &record, nil
+}
+
+func (s *Store) path(domain string) string {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	return filepath.Join(s.dir, filepath.Base(name)+".json")
+}
+
+// Explain prints the decision trace: the verdict, then every checker in the
+// order it ran. Evidence supporting the verdict is marked with "*".
+func Explain(w io.Writer, record *Record) {
+	fmt.Fprintf(w, "Domain:  %s\n", record.Domain)
+	fmt.Fprintf(w, "Verdict: %s (confidence %.2f)\n", record.Status, record.Confidence)
+	if record.Reason != "" {
+		fmt.Fprintf(w, "Reason:  %s\n", record.Reason)
+	}
+	if record.Server != "" {
+		fmt.Fprintf(w, "Server:  %s\n", record.Server)
+	}
+	fmt.Fprintf(w, "Checked: %s (%d ms)\n", record.CheckedAt.Format(time.RFC3339), record.LatencyMs)
+
+	fmt.Fprintf(w, "\nEvidence:\n")
+	if len(record.Evidence) == 0 {
+		fmt.Fprintf(w, "  (none)\n")
+	}
+	for i, e := range record.Evidence {
+		marker := " "
+		if e.Status == record.Status && record.Status != types.StatusUnknown {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %d. %-8s %-12s %d ms", marker, i+1, e.Checker, e.Status, e.LatencyMs)
+		if e.Conclusive {
+			fmt.Fprintf(w, "  conclusive")
+		}
+		fmt.Fprintln(w)
+
+		if e.Server != "" {
+			fmt.Fprintf(w, "     server:  %s\n", e.Server)
+		}
+		if e.Detail != "" {
+			fmt.Fprintf(w, "     detail:  %s\n", e.Detail)
+		}
+		if e.Matched != "" {
+			fmt.Fprintf(w, "     matched: %q\n", e.Matched)
+		}
+		if e.Error != "" {
+			fmt.Fprintf(w, "     error:   %s\n", e.Error)
+		}
+		if e.Raw != "" {
+			raw := e.Raw
+			var indented bytes.Buffer
+			if strings.HasPrefix(raw, "{") && json.Indent(&indented, []byte(raw), "", "  ") == nil {
+				raw = indented.String() // RDAP JSON
+			}
+			fmt.Fprintf(w, "     raw:\n")
+			for _, line := range strings.Split(strings.TrimRight(strings.ReplaceAll(raw, "\r\n", "\n"), "\n"), "\n") {
+				fmt.Fprintf(w, "       | %s\n", line)
+			}
+		}
+	}
+}
@@ -2,27 +2,34 @@ package generator
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"domain_scanner/internal/idn"
+	"domain_scanner/internal/types"
+
 	"github.com/dlclark/regexp2"
 )
 
 // DomainGenerator 包含生成的域名和计数信息
 type DomainGenerator struct {
-	Domains     <-chan string
-	TotalCount  int
-	Generated   *int64 // 用atomic操作的计数器
+	Domains    <-chan types.Candidate
+	TotalCount int
+	Generated  *int64 // 用atomic操作的计数器
+	Enumerated bool   // 正则表达式被直接枚举，TotalCount 为精确的匹配数
 }
 
-// GenerateDomains 返回一个包含域名和计数信息的结构体
-func GenerateDomains(length int, suffix string, pattern string, regexFilter string, dictFile string) *DomainGenerator {
-	letters := "abcdefghijklmnopqrstuvwxyz"
-	numbers := "0123456789"
-
+// GenerateDomains 返回一个包含域名和计数信息的结构体，ctx 取消时停止生成并关闭通道
+// 候选域名均为 A-label 形式（国际化域名已转换为 xn--），正则表达式匹配 U-label
+// 非字典模式依次按各掩码生成候选（传统 -p/-l 参数见 PatternMasks），
+// 后一个掩码的候选索引接在前一个之后
+// 每个标签依次搭配所有后缀，相邻的查询分散到不同注册局，见 sendLabel
+// start 为起始索引（用于断点续扫），索引小于 start 的候选域名不会生成
+func GenerateDomains(ctx context.Context, masks []*Mask, suffixes []string, regexFilter string, dictFile string, start int64) *DomainGenerator {
 	var regex *regexp2.Regexp
 	var err error
 	if regexFilter != "" {
@@ -42,9 +49,10 @@ func GenerateDomains(length int, suffix string, pattern string, regexFilter stri
 		regex.MatchTimeout = 100 * time.Millisecond
 	}
 
-	domainChan := make(chan string, 1000) // 缓冲池以提高性能
+	domainChan := make(chan types.Candidate, 1000) // 缓冲池以提高性能
 	var generated int64 = 0
 	var totalEstimated int
+	var enumerators []*regexEnumerator
 	
 	// 字典模式或传统模式的预估计算
 	if dictFile != "" {
@@ -54,43 +62,47 @@ func GenerateDomains(length int, suffix string, pattern string, regexFilter stri
 			os.Exit(1)
 		} else {
 			totalEstimated = len(words)
+			reportInvalidWords(words)
 		}
 	} else {
-		// 传统模式：计算组合数量
-		var charsetSize int
-		switch pattern {
-		case "d":
-			charsetSize = len(numbers)
-		case "D":
-			charsetSize = len(letters)
-		case "a":
-			charsetSize = len(letters + numbers)
+		for _, mask := range masks {
+			// 正则模式：尽量直接枚举匹配的标签，不支持的特性（如反向引用）退回逐个过滤
+			if regex != nil {
+				if enumerator, err := newRegexEnumerator(regexFilter, mask); err == nil {
+					enumerators = append(enumerators, enumerator)
+					totalEstimated += int(enumerator.Count())
+					continue
+				}
+			}
+			// 掩码模式：合法标签数即精确数量
+			totalEstimated += int(mask.Count())
 		}
-		
-		totalEstimated = 1
-		for i := 0; i < length; i++ {
-			totalEstimated *= charsetSize
+		if len(enumerators) != len(masks) {
+			enumerators = nil
 		}
 	}
+	totalEstimated *= len(suffixes)
 
 	go func() {
 		defer close(domainChan)
 
 		if dictFile != "" {
 			// 字典模式：从文件读取单词
-			generateFromDictionary(domainChan, dictFile, suffix, regex, &generated)
+			generateFromDictionary(ctx, domainChan, dictFile, suffixes, regex, start, &generated)
 		} else {
-			// 传统模式：生成字符组合
-			switch pattern {
-			case "d":
-				generateCombinationsIterative(domainChan, numbers, length, suffix, regex, &generated)
-			case "D":
-				generateCombinationsIterative(domainChan, letters, length, suffix, regex, &generated)
-			case "a":
-				generateCombinationsIterative(domainChan, letters+numbers, length, suffix, regex, &generated)
-			default:
-				fmt.Println("Invalid pattern. Use -d for numbers, -D for letters, -a for alphanumeric")
-				os.Exit(1)
+			var offset int64
+			for i, mask := range masks {
+				if ctx.Err() != nil {
+					return
+				}
+				if enumerators != nil {
+					// 枚举模式：只生成匹配正则表达式的标签
+					enumerators[i].generate(ctx, domainChan, suffixes, regex, offset, start, &generated)
+				} else {
+					// 掩码模式：只生成匹配掩码的字符组合
+					generateCombinationsIterative(ctx, domainChan, mask, suffixes, regex, offset, start, &generated)
+				}
+				offset += mask.Size()
 			}
 		}
 	}()
@@ -99,33 +111,23 @@ func GenerateDomains(length int, suffix string, pattern string, regexFilter stri
 		Domains:    domainChan,
 		TotalCount: totalEstimated,
 		Generated:  &generated,
+		Enumerated: enumerators != nil,
 	}
 }
 
 // generateCombinationsIterative 使用迭代方法而非递归方法防止堆栈溢出
-func generateCombinationsIterative(domainChan chan<- string, charset string, length int, suffix string, regex *regexp2.Regexp, generated *int64) {
-	charsetSize := len(charset)
-	if charsetSize == 0 || length <= 0 {
-		return
-	}
-
+// offset 为该掩码第一个标签的标签索引
+func generateCombinationsIterative(ctx context.Context, domainChan chan<- types.Candidate, mask *Mask, suffixes []string, regex *regexp2.Regexp, offset int64, start int64, generated *int64) {
 	// 使用计数器方法生成组合
-	total := 1
-	for i := 0; i < length; i++ {
-		total *= charsetSize
-	}
-
-	for counter := 0; counter < total; counter++ {
-		current := ""
-		temp := counter
+	total := mask.Size()
 
+	// 计数器加 offset 即标签索引，从 start 所在的标签开始即可续扫
+	for counter := max(start/int64(len(suffixes))-offset, 0); counter < total; counter++ {
 		// 从计数器生成域名字符串
-		for i := 0; i < length; i++ {
-			current = string(charset[temp%charsetSize]) + current
-			temp /= charsetSize
+		current := mask.Label(counter)
+		if !mask.Valid(current) {
+			continue
 		}
-
-		domain := current + suffix
 		
 		// 正则过滤（只对域名前缀进行匹配）
 		var match bool
@@ -140,14 +142,47 @@ func generateCombinationsIterative(domainChan chan<- string, charset string, len
 			}
 		}
 
-		if match {
-			domainChan <- domain
-			// 使用atomic操作增加计数器
-			atomic.AddInt64(generated, 1)
+		if match && !sendLabel(ctx, domainChan, current, offset+counter, suffixes, start, generated) {
+			return
 		}
 	}
 }
 
+// sendLabel 发送标签搭配各后缀的候选域名，候选索引为 标签索引*后缀数+后缀序号，
+// 单个后缀时即标签索引。索引小于 start 的候选跳过
+// U-label 转换为 A-label 后发送，违反 IDNA 规则的标签跳过
+func sendLabel(ctx context.Context, domainChan chan<- types.Candidate, label string, labelIndex int64, suffixes []string, start int64, generated *int64) bool {
+	if !idn.IsASCII(label) {
+		ascii, err := idn.ToASCII(label)
+		if err != nil {
+			return true
+		}
+		label = ascii
+	}
+	for i, suffix := range suffixes {
+		index := labelIndex*int64(len(suffixes)) + int64(i)
+		if index < start {
+			continue
+		}
+		if !sendDomain(ctx, domainChan, types.Candidate{Domain: label + suffix, Index: index}) {
+			return false
+		}
+		// 使用atomic操作增加计数器
+		atomic.AddInt64(generated, 1)
+	}
+	return true
+}
+
+// sendDomain 发送候选域名，ctx 取消时返回 false
+func sendDomain(ctx context.Context, domainChan chan<- types.Candidate, candidate types.Candidate) bool {
+	select {
+	case domainChan <- candidate:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 // validateRegexComplexity 检查正则表达式的复杂度，防止潜在的 ReDoS 攻击
 func validateRegexComplexity(pattern string) error {
 	// 检查长度限制
@@ -227,16 +262,61 @@ func readDictionaryFile(dictFile string) ([]string, error) {
 	return words, nil
 }
 
+// normalizeWord 按 UTS-46 规范化字典单词（大小写、全角等）并转换为 A-label，
+// 不合法或混用文字的单词返回错误
+func normalizeWord(word string) (string, error) {
+	return idn.ToASCII(word)
+}
+
+// maxReportedWords 为启动时逐个列出的无效字典单词数
+const maxReportedWords = 10
+
+// reportInvalidWords 列出字典中将被跳过的单词
+func reportInvalidWords(words []string) {
+	invalid := 0
+	for _, word := range words {
+		if _, err := normalizeWord(word); err != nil {
+			invalid++
+			if invalid <= maxReportedWords {
+				fmt.Printf("Skipping dictionary word: %v\n", err)
+			}
+		}
+	}
+	if invalid > maxReportedWords {
+		fmt.Printf("Skipping %d more invalid dictionary words\n", invalid-maxReportedWords)
+	}
+}
+
 // generateFromDictionary 从字典文件生成域名
-func generateFromDictionary(domainChan chan<- string, dictFile string, suffix string, regex *regexp2.Regexp, generated *int64) {
+func generateFromDictionary(ctx context.Context, domainChan chan<- types.Candidate, dictFile string, suffixes []string, regex *regexp2.Regexp, start int64, generated *int64) {
 	words, err := readDictionaryFile(dictFile)
 	if err != nil {
 		fmt.Printf("Error reading dictionary: %v\n", err)
 		return
 	}
 
-	for _, word := range words {
-		domain := word + suffix
+	for index, word := range words {
+		// 单词序号即标签索引
+		if int64(index) < start/int64(len(suffixes)) {
+			continue
+		}
+
+		// 无效单词已在启动时列出
+		word, err := normalizeWord(word)
+		if err != nil {
+			continue
+		}
+
+		// 已带后缀的条目（如 unknown_domains 文件）只在该后缀下检查
+		only := -1
+		for i, suffix := range suffixes {
+			if strings.HasSuffix(word, suffix) && (only < 0 || len(suffix) > len(suffixes[only])) {
+				only = i
+			}
+		}
+		if only >= 0 {
+			word = strings.TrimSuffix(word, suffixes[only])
+		}
 		
 		// 正则过滤（只对域名前缀进行匹配）
 		var match bool
@@ -244,16 +324,27 @@ func generateFromDictionary(domainChan chan<- string, dictFile string, suffix st
 			match = true
 		} else {
 			var err error
-			match, err = safeRegexMatch(regex, word)
+			match, err = safeRegexMatch(regex, idn.ToUnicode(word))
 			if err != nil {
 				// 正则匹配错误时跳过该域名
 				match = false
 			}
 		}
 
-		if match {
-			domainChan <- domain
-			// 使用atomic操作增加计数器
+		if !match {
+			continue
+		}
+		if only < 0 {
+			if !sendLabel(ctx, domainChan, word, int64(index), suffixes, start, generated) {
+				return
+			}
+			continue
+		}
+		candidate := types.Candidate{Domain: word + suffixes[only], Index: int64(index)*int64(len(suffixes)) + int64(only)}
+		if candidate.Index >= start {
+			if !sendDomain(ctx, domainChan, candidate) {
+				return
+			}
 			atomic.AddInt64(generated, 1)
 		}
 	}
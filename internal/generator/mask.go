@@ -0,0 +1,356 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"domain_scanner/internal/idn"
+)
+
+const (
+	lowerLetters = "abcdefghijklmnopqrstuvwxyz"
+	digits       = "0123456789"
+	// 域名标签允许的字符
+	labelChars = lowerLetters + digits + "-"
+	// 域名标签最大长度
+	maxLabelLength = 63
+)
+
+// Mask 描述逐位置的候选标签：每个位置是一个字符集（字面字符即单字符集合）。
+// 语法：
+//
+//	?l         小写字母 a-z
+//	?d         数字 0-9
+//	?a         字母和数字
+//	?1 ... ?9  自定义字符集（见 ParseCharsets）
+//	[aeiou]    内联字符集，支持区间，如 [a-f0-9]
+//	??         字面字符 ?（标签中不合法，仅为完整性保留）
+//	其他字符    字面字符
+//
+// 例如 x?l?l 只生成 x 开头的 676 个三字符标签。
+// 字符集和字面字符可以是 Unicode 字符（如 m[uü]nchen、北京?1），
+// 生成的是 U-label，发送前转换为 A-label（xn--），见 sendLabel
+type Mask struct {
+	source    string
+	positions [][]rune // 每个位置的字符集，已去重并排序
+}
+
+// ParseCharsets 解析自定义字符集定义，格式为 "1=[aeiou],2=xyz"（方括号可省略）
+func ParseCharsets(spec string) (map[byte]string, error) {
+	charsets := make(map[byte]string)
+	for _, definition := range strings.Split(spec, ",") {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+		name, class, found := strings.Cut(definition, "=")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "?")
+		if !found || len(name) != 1 || name[0] < '1' || name[0] > '9' {
+			return nil, fmt.Errorf("invalid charset definition %q (use 1=[aeiou] ... 9=[...])", definition)
+		}
+		class = strings.TrimSpace(class)
+		if strings.HasPrefix(class, "[") && strings.HasSuffix(class, "]") {
+			class = class[1 : len(class)-1]
+		}
+		chars, err := expandClass(class)
+		if err != nil {
+			return nil, fmt.Errorf("charset ?%s: %w", name, err)
+		}
+		charsets[name[0]] = chars
+	}
+	return charsets, nil
+}
+
+// ParseMask 解析掩码，charsets 为 ?1-?9 的自定义字符集（可为 nil）
+func ParseMask(mask string, charsets map[byte]string) (*Mask, error) {
+	m := &Mask{source: mask}
+	runes := []rune(mask)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '?':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("mask %q ends with a lone ?", mask)
+			}
+			i++
+			switch class := runes[i]; {
+			case class == 'l':
+				m.positions = append(m.positions, []rune(lowerLetters))
+			case class == 'd':
+				m.positions = append(m.positions, []rune(digits))
+			case class == 'a':
+				m.positions = append(m.positions, []rune(lowerLetters+digits))
+			case class == '?':
+				m.positions = append(m.positions, []rune{'?'})
+			case class >= '1' && class <= '9':
+				chars, ok := charsets[byte(class)]
+				if !ok {
+					return nil, fmt.Errorf("mask %q uses undefined charset ?%c", mask, class)
+				}
+				m.positions = append(m.positions, []rune(chars))
+			default:
+				return nil, fmt.Errorf("mask %q uses unknown class ?%c (use ?l, ?d, ?a or ?1-?9)", mask, class)
+			}
+		case '[':
+			end := slices.Index(runes[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("mask %q has an unterminated [", mask)
+			}
+			chars, err := expandClass(string(runes[i+1 : i+end]))
+			if err != nil {
+				return nil, fmt.Errorf("mask %q: %w", mask, err)
+			}
+			m.positions = append(m.positions, []rune(chars))
+			i += end
+		default:
+			m.positions = append(m.positions, []rune{unicode.ToLower(c)})
+		}
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PatternMask 把传统的 -p/-l 参数转换为等价掩码，候选索引与原实现一致。
+// hyphens 为 true 时中间位置还可以是 -（排在其他字符之后）
+func PatternMask(pattern string, length int, hyphens bool) (*Mask, error) {
+	var class, chars string
+	switch pattern {
+	case "d":
+		class, chars = "?d", digits
+	case "D":
+		class, chars = "?l", lowerLetters
+	case "a":
+		class, chars = "?a", lowerLetters+digits
+	default:
+		return nil, fmt.Errorf("invalid pattern %q, use d for numbers, D for letters, a for alphanumeric", pattern)
+	}
+	if !hyphens || length < 3 {
+		return ParseMask(strings.Repeat(class, length), nil)
+	}
+
+	m := &Mask{source: class + strings.Repeat("["+chars+"-]", length-2) + class}
+	m.positions = append(m.positions, []rune(chars))
+	for i := 1; i < length-1; i++ {
+		m.positions = append(m.positions, []rune(chars+"-"))
+	}
+	m.positions = append(m.positions, []rune(chars))
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PatternMasks 返回 minLength 到 maxLength 每个长度的掩码，按长度升序
+func PatternMasks(pattern string, minLength, maxLength int, hyphens bool) ([]*Mask, error) {
+	if minLength < 1 || maxLength < minLength {
+		return nil, fmt.Errorf("invalid length range %d-%d", minLength, maxLength)
+	}
+	var masks []*Mask
+	for length := minLength; length <= maxLength; length++ {
+		m, err := PatternMask(pattern, length, hyphens)
+		if err != nil {
+			return nil, err
+		}
+		masks = append(masks, m)
+	}
+	return masks, nil
+}
+
+// validate 确保掩码只能生成合法的域名标签
+func (m *Mask) validate() error {
+	if len(m.positions) == 0 {
+		return fmt.Errorf("mask %q is empty", m.source)
+	}
+	if len(m.positions) > maxLabelLength {
+		return fmt.Errorf("mask %q is longer than %d characters", m.source, maxLabelLength)
+	}
+	var unicodeChars []rune
+	for _, chars := range m.positions {
+		for _, c := range chars {
+			if c >= utf8.RuneSelf {
+				// Unicode 字符须是 IDNA2008 允许且已规范化的形式（如 ä 而不是 Ä）
+				if err := idn.CheckRune(c); err != nil {
+					return fmt.Errorf("mask %q: %w", m.source, err)
+				}
+				unicodeChars = append(unicodeChars, c)
+				continue
+			}
+			if !strings.ContainsRune(labelChars, c) {
+				return fmt.Errorf("mask %q contains %q, labels may only use a-z, 0-9, - and Unicode letters", m.source, c)
+			}
+		}
+	}
+	// 整个掩码只能使用一种文字（或允许的中日韩文字与拉丁字母组合），
+	// 这样生成的标签都不会混用文字
+	if len(unicodeChars) > 0 {
+		var all []rune
+		for _, chars := range m.positions {
+			all = append(all, chars...)
+		}
+		if err := idn.CheckScripts(string(all)); err != nil {
+			return fmt.Errorf("mask %q mixes scripts (%s), which is not allowed in a label", m.source, strings.Join(idn.Scripts(string(all)), ", "))
+		}
+	}
+	if slices.Contains(m.positions[0], '-') || slices.Contains(m.positions[len(m.positions)-1], '-') {
+		return fmt.Errorf("mask %q can put a hyphen at the start or end of the label", m.source)
+	}
+	if len(m.positions) >= 4 && string(m.positions[2]) == "-" && string(m.positions[3]) == "-" {
+		return fmt.Errorf("mask %q puts -- at positions 3-4, which is reserved for IDN labels (xn--); write IDNs in Unicode, e.g. m[uü]nchen", m.source)
+	}
+
+	// 候选索引为 int64，防止溢出
+	count := int64(1)
+	for _, chars := range m.positions {
+		if count > math.MaxInt64/int64(len(chars)) {
+			return fmt.Errorf("mask %q generates too many labels", m.source)
+		}
+		count *= int64(len(chars))
+	}
+	return nil
+}
+
+// hasUnicode 判断掩码是否含 Unicode 字符
+func (m *Mask) hasUnicode() bool {
+	for _, chars := range m.positions {
+		if !idn.IsASCII(string(chars)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len 返回标签长度
+func (m *Mask) Len() int {
+	return len(m.positions)
+}
+
+// Size 返回候选索引的范围，即各位置字符集大小之积（含违反标签规则的组合）
+func (m *Mask) Size() int64 {
+	size := int64(1)
+	for _, chars := range m.positions {
+		size *= int64(len(chars))
+	}
+	return size
+}
+
+// Count 返回掩码生成的合法标签数（精确值）。
+// 含 Unicode 字符时为上限：少数违反 IDNA 双向文字或连接符规则的标签在发送时跳过
+func (m *Mask) Count() int64 {
+	// 按标签规则状态分组计数
+	counts := map[uint8]int64{ruleNone: 1}
+	for i, chars := range m.positions {
+		next := make(map[uint8]int64)
+		for rule, n := range counts {
+			for j := 0; j < len(chars); j++ {
+				if r, ok := labelRule(rule, chars[j], i); ok {
+					next[r] += n
+				}
+			}
+		}
+		counts = next
+	}
+
+	var count int64
+	for _, n := range counts {
+		count += n
+	}
+	return count
+}
+
+// TotalCount 返回各掩码生成的合法标签总数
+func TotalCount(masks []*Mask) int64 {
+	var count int64
+	for _, m := range masks {
+		count += m.Count()
+	}
+	return count
+}
+
+// Label 返回第 index 个标签，最后一个位置变化最快；标签可能违反标签规则，见 Valid
+func (m *Mask) Label(index int64) string {
+	label := make([]rune, len(m.positions))
+	for i := len(m.positions) - 1; i >= 0; i-- {
+		size := int64(len(m.positions[i]))
+		label[i] = m.positions[i][index%size]
+		index /= size
+	}
+	return string(label)
+}
+
+func (m *Mask) String() string {
+	return m.source
+}
+
+// Valid 检查 Label 生成的标签是否符合标签规则
+func (m *Mask) Valid(label string) bool {
+	rule := ruleNone
+	for i, c := range []rune(label) {
+		var ok bool
+		if rule, ok = labelRule(rule, c, i); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 标签规则状态：第 3、4 位的 "--" 保留给 IDN 标签（xn--）。
+// 生成的标签一律不能使用：随意拼出的 xn-- 标签几乎都不是合法的 punycode，
+// 国际化域名以 Unicode 字符写在掩码中，发送前再转换
+const (
+	ruleNone   uint8 = iota
+	ruleHyphen       // 第 3 位为 -
+)
+
+// labelRule 在第 i 位读入字符 c 后更新规则状态，违反规则时返回 false。
+// 开头和结尾的 - 已由 validate 排除
+func labelRule(rule uint8, c rune, i int) (uint8, bool) {
+	switch i {
+	case 2:
+		if c == '-' {
+			return ruleHyphen, true
+		}
+	case 3:
+		if c == '-' && rule == ruleHyphen {
+			return ruleNone, false
+		}
+	}
+	return ruleNone, true
+}
+
+// expandClass 展开字符集内容（不含方括号），支持 a-z、а-я 形式的区间
+func expandClass(class string) (string, error) {
+	runes := []rune(class)
+	seen := make(map[rune]bool)
+	for i := 0; i < len(runes); i++ {
+		from := runes[i]
+		to := from
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			to = runes[i+2]
+			i += 2
+		}
+		if from > to {
+			return "", fmt.Errorf("invalid range %c-%c", from, to)
+		}
+		for c := from; c <= to; c++ {
+			seen[unicode.ToLower(c)] = true
+		}
+	}
+	if len(seen) == 0 {
+		return "", fmt.Errorf("empty charset")
+	}
+
+	chars := make([]rune, 0, len(seen))
+	for c := range seen {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	return string(chars), nil
+}
@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// collect 返回生成的全部域名
+func collect(t *testing.T, masks []*Mask, suffixes []string, regex string) []string {
+	t.Helper()
+	g := GenerateDomains(context.Background(), masks, suffixes, regex, "", 0)
+	var domains []string
+	for candidate := range g.Domains {
+		domains = append(domains, candidate.Domain)
+	}
+	return domains
+}
+
+func TestHyphenatedLabelsNeverUseReservedPositions(t *testing.T) {
+	masks, err := PatternMasks("d", 4, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domains := collect(t, masks, []string{".li"}, "")
+	if int64(len(domains)) != TotalCount(masks) {
+		t.Errorf("generated %d labels, TotalCount says %d", len(domains), TotalCount(masks))
+	}
+	for _, domain := range domains {
+		if label := strings.TrimSuffix(domain, ".li"); label[2:4] == "--" {
+			t.Errorf("generated %s with -- at positions 3-4", domain)
+		}
+	}
+}
+
+func TestMaskRejectsMadeUpALabels(t *testing.T) {
+	for _, mask := range []string{"xn--?l", "?l?l--?l", "ab--cd"} {
+		if _, err := ParseMask(mask, nil); err == nil {
+			t.Errorf("ParseMask(%q) should reject -- at positions 3-4", mask)
+		}
+	}
+
+	// Unicode 掩码在发送前才转换为 A-label
+	domains := collect(t, mustParseMask(t, "m[uü]nchen"), []string{".de"}, "")
+	want := []string{"munchen.de", "xn--mnchen-3ya.de"}
+	if strings.Join(domains, " ") != strings.Join(want, " ") {
+		t.Errorf("generated %v, want %v", domains, want)
+	}
+}
+
+func mustParseMask(t *testing.T, mask string) []*Mask {
+	t.Helper()
+	m, err := ParseMask(mask, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*Mask{m}
+}
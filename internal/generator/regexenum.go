@@ -0,0 +1,247 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"domain_scanner/internal/types"
+
+	"github.com/dlclark/regexp2"
+)
+
+// regexEnumerator 直接枚举正则表达式在掩码范围内匹配的标签，
+// 而不是逐个生成掩码的全部组合再过滤。
+// 支持的子集：锚点、字符类、重复（含有界重复）、分支和字面字符；
+// 反向引用、环视、单词边界等特性无法枚举，调用方需退回过滤模式。
+// RE2 的 \w、\d、\s 只匹配 ASCII，regexp2 按 Unicode 匹配，
+// 掩码含 Unicode 字符时这类正则表达式同样退回过滤模式
+type regexEnumerator struct {
+	prog   *syntax.Prog
+	mask   *Mask
+	remain []int64            // remain[i] 为第 i 位及之后位置的组合数（候选索引范围）
+	counts []map[string]int64 // counts[i][状态] 为从第 i 位起能匹配的标签数
+}
+
+// regexState 是读入若干字符后 NFA 所处的状态集合
+type regexState struct {
+	pcs     []uint32 // 等待读入字符的指令，已排序
+	matched bool     // 已匹配成功，之后的字符不再影响结果
+	rule    uint8    // 标签规则状态，见 labelRule
+	dead    bool     // 违反标签规则，不会再生成标签
+}
+
+func (s regexState) key() string {
+	var b strings.Builder
+	b.WriteByte('0' + s.rule)
+	if s.matched {
+		b.WriteByte('M')
+		return b.String()
+	}
+	for _, pc := range s.pcs {
+		fmt.Fprintf(&b, "%d,", pc)
+	}
+	return b.String()
+}
+
+// newRegexEnumerator 编译正则表达式，无法枚举时返回错误
+func newRegexEnumerator(pattern string, mask *Mask) (*regexEnumerator, error) {
+	if mask.hasUnicode() && usesPerlClasses(pattern) {
+		return nil, fmt.Errorf("cannot enumerate regex: \\w, \\d and \\s only match ASCII in RE2")
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		// 反向引用、环视等 regexp2 特性
+		return nil, fmt.Errorf("cannot enumerate regex: %w", err)
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("cannot enumerate regex: %w", err)
+	}
+	for _, inst := range prog.Inst {
+		if inst.Op == syntax.InstEmptyWidth && syntax.EmptyOp(inst.Arg)&(syntax.EmptyWordBoundary|syntax.EmptyNoWordBoundary) != 0 {
+			return nil, fmt.Errorf("cannot enumerate regex: word boundaries are not supported")
+		}
+	}
+
+	e := &regexEnumerator{
+		prog:   prog,
+		mask:   mask,
+		remain: make([]int64, mask.Len()+1),
+		counts: make([]map[string]int64, mask.Len()+1),
+	}
+	e.remain[mask.Len()] = 1
+	for i := mask.Len() - 1; i >= 0; i-- {
+		e.remain[i] = e.remain[i+1] * int64(len(mask.positions[i]))
+	}
+	for i := range e.counts {
+		e.counts[i] = make(map[string]int64)
+	}
+	return e, nil
+}
+
+// usesPerlClasses 判断正则表达式是否使用 \w、\d、\s 及其反义形式
+func usesPerlClasses(pattern string) bool {
+	for i := 0; i+1 < len(pattern); i++ {
+		if pattern[i] != '\\' {
+			continue
+		}
+		if strings.IndexByte("wWdDsS", pattern[i+1]) >= 0 {
+			return true
+		}
+		i++ // 跳过被转义的字符，如 \\w 中的第二个反斜杠
+	}
+	return false
+}
+
+// start 返回读入前 i 个字符之前的状态（i 为 0）
+func (e *regexEnumerator) start() regexState {
+	return e.closure(nil, 0)
+}
+
+// closure 在第 i 位加入从 pcs 出发经空转移可达的指令。
+// 每一位都重新从起点开始，即在标签任意位置查找匹配（与过滤模式的 MatchString 一致）
+func (e *regexEnumerator) closure(pcs []uint32, i int) regexState {
+	var state regexState
+	seen := make(map[uint32]bool)
+	var add func(pc uint32)
+	add = func(pc uint32) {
+		if seen[pc] || state.matched {
+			return
+		}
+		seen[pc] = true
+		inst := &e.prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			add(inst.Out)
+			add(inst.Arg)
+		case syntax.InstNop, syntax.InstCapture:
+			add(inst.Out)
+		case syntax.InstEmptyWidth:
+			// 标签不含换行，行首/行尾等同于开头/结尾
+			var holds syntax.EmptyOp
+			if i == 0 {
+				holds |= syntax.EmptyBeginText | syntax.EmptyBeginLine
+			}
+			if i == e.mask.Len() {
+				holds |= syntax.EmptyEndText | syntax.EmptyEndLine
+			}
+			if syntax.EmptyOp(inst.Arg)&^holds == 0 {
+				add(inst.Out)
+			}
+		case syntax.InstMatch:
+			state.matched = true
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			state.pcs = append(state.pcs, pc)
+		}
+	}
+
+	for _, pc := range pcs {
+		add(pc)
+	}
+	add(uint32(e.prog.Start))
+	if state.matched {
+		return regexState{matched: true}
+	}
+	sort.Slice(state.pcs, func(a, b int) bool { return state.pcs[a] < state.pcs[b] })
+	return state
+}
+
+// step 返回第 i 位读入字符 c 后的状态
+func (e *regexEnumerator) step(state regexState, c rune, i int) regexState {
+	rule, ok := labelRule(state.rule, c, i)
+	if !ok {
+		return regexState{dead: true}
+	}
+	if state.matched {
+		return regexState{matched: true, rule: rule}
+	}
+	var next []uint32
+	for _, pc := range state.pcs {
+		inst := &e.prog.Inst[pc]
+		if inst.MatchRune(c) {
+			next = append(next, inst.Out)
+		}
+	}
+	state = e.closure(next, i+1)
+	state.rule = rule
+	return state
+}
+
+// count 返回从第 i 位、给定状态起能匹配的标签数
+func (e *regexEnumerator) count(state regexState, i int) int64 {
+	if state.dead {
+		return 0
+	}
+	// 标签规则只涉及前 4 位，之后的组合都合法
+	if state.matched && i >= 4 {
+		return e.remain[i]
+	}
+	if i == e.mask.Len() {
+		if state.matched {
+			return 1
+		}
+		return 0
+	}
+	key := state.key()
+	if n, ok := e.counts[i][key]; ok {
+		return n
+	}
+	var n int64
+	for _, c := range e.mask.positions[i] {
+		n += e.count(e.step(state, c, i), i+1)
+	}
+	e.counts[i][key] = n
+	return n
+}
+
+// Count 返回匹配的标签总数（精确值）
+func (e *regexEnumerator) Count() int64 {
+	return e.count(e.start(), 0)
+}
+
+// generate 按掩码索引升序发送匹配的标签搭配各后缀的域名，标签索引（加 offset）
+// 与过滤模式一致，断点续扫可以互通
+func (e *regexEnumerator) generate(ctx context.Context, domainChan chan<- types.Candidate, suffixes []string, regex *regexp2.Regexp, offset int64, start int64, generated *int64) {
+	labelStart := max(start/int64(len(suffixes))-offset, 0)
+	label := make([]rune, e.mask.Len())
+	var walk func(state regexState, i int, index int64) bool
+	walk = func(state regexState, i int, index int64) bool {
+		// 没有匹配的标签，或整棵子树都在起始索引之前
+		if e.count(state, i) == 0 || (index+1)*e.remain[i] <= labelStart {
+			return true
+		}
+		if i == e.mask.Len() {
+			current := string(label)
+			// 仍用 regexp2 复核，结果与过滤模式完全一致
+			if match, err := safeRegexMatch(regex, current); err != nil || !match {
+				return true
+			}
+			return sendLabel(ctx, domainChan, current, offset+index, suffixes, start, generated)
+		}
+		chars := e.mask.positions[i]
+		for j := 0; j < len(chars); j++ {
+			label[i] = chars[j]
+			if !walk(e.step(state, chars[j], i), i+1, index*int64(len(chars))+int64(j)) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(e.start(), 0, 0)
+}
+
+// CountRegexMatches 返回各掩码生成的标签中匹配正则表达式的总数；
+// 正则表达式无法直接枚举时 ok 为 false
+func CountRegexMatches(masks []*Mask, pattern string) (count int64, ok bool) {
+	for _, mask := range masks {
+		e, err := newRegexEnumerator(pattern, mask)
+		if err != nil {
+			return 0, false
+		}
+		count += e.Count()
+	}
+	return count, true
+}
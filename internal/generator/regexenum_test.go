@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"domain_scanner/internal/types"
+
+	"github.com/dlclark/regexp2"
+)
+
+// filtered 逐个过滤掩码的全部组合，作为枚举结果的参照
+func filtered(t *testing.T, mask *Mask, suffix string, pattern string) []string {
+	t.Helper()
+	regex, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domainChan := make(chan types.Candidate, 1000)
+	var generated int64
+	go func() {
+		defer close(domainChan)
+		generateCombinationsIterative(context.Background(), domainChan, mask, []string{suffix}, regex, 0, 0, &generated)
+	}()
+	var domains []string
+	for candidate := range domainChan {
+		domains = append(domains, candidate.Domain)
+	}
+	return domains
+}
+
+func TestRegexEnumerationMatchesFiltering(t *testing.T) {
+	tests := []struct {
+		mask    string
+		pattern string
+		want    int // 匹配的标签数
+	}{
+		{mask: "m[uü]nchen", pattern: `^\w+$`, want: 2},
+		{mask: "m[uü]nchen", pattern: `^m\W`, want: 0},
+		{mask: "m[uü]nchen", pattern: `^m[^\d]n`, want: 2},
+		{mask: "m[uü]nchen", pattern: `^\p{L}+$`, want: 2},
+		{mask: "m[uü]nchen", pattern: `ü`, want: 1},
+		{mask: "[aä1]?d", pattern: `^\w\d$`, want: 30},
+		{mask: "[aä1]?d", pattern: `^\D`, want: 20},
+		{mask: "?l?d", pattern: `^\w\d$`, want: 260},
+		{mask: "?l?d", pattern: `^[a-c]\\?\d`, want: 30},
+	}
+	for _, test := range tests {
+		t.Run(test.mask+" "+test.pattern, func(t *testing.T) {
+			masks := mustParseMask(t, test.mask)
+			want := filtered(t, masks[0], ".de", test.pattern)
+			if len(want) != test.want {
+				t.Fatalf("filtering gives %d labels, want %d: %v", len(want), test.want, want)
+			}
+
+			g := GenerateDomains(context.Background(), masks, []string{".de"}, test.pattern, "", 0)
+			var got []string
+			for candidate := range g.Domains {
+				got = append(got, candidate.Domain)
+			}
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("generated %v, filtering gives %v", got, want)
+			}
+			if g.Enumerated && g.TotalCount != len(want) {
+				t.Errorf("TotalCount = %d, want %d", g.TotalCount, len(want))
+			}
+			if count, ok := CountRegexMatches(masks, test.pattern); ok && count != int64(len(want)) {
+				t.Errorf("CountRegexMatches = %d, want %d", count, len(want))
+			} else if ok != g.Enumerated {
+				t.Errorf("CountRegexMatches ok = %v, but enumerated = %v", ok, g.Enumerated)
+			}
+		})
+	}
+
+	// 纯 ASCII 掩码的 \w、\d 照常枚举
+	if _, ok := CountRegexMatches(mustParseMask(t, "?l?d"), `^\w\d$`); !ok {
+		t.Error("an ASCII mask with \\w should be enumerated")
+	}
+}
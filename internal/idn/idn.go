@@ -0,0 +1,169 @@
+// Package idn converts internationalized domain names between the Unicode
+// form shown to users (U-labels, e.g. münchen) and the ASCII form used in
+// DNS, WHOIS and RDAP queries (A-labels, e.g. xn--mnchen-3ya)
+package idn
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// profile maps names as UTS #46 does for lookups (case folding, width and
+// compatibility mappings) and validates the labels per IDNA2008, including
+// the hyphen, joiner and Bidi rules
+var profile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+)
+
+// scriptMixes are the combinations of scripts a label may use together, the
+// "highly restrictive" level of UTS #39: Chinese, Japanese and Korean
+// writing mixed with Latin. Any other label must stick to one script.
+var scriptMixes = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// scriptNames lists the script tables in a fixed order
+var scriptNames = func() []string {
+	names := make([]string, 0, len(unicode.Scripts))
+	for name := range unicode.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}()
+
+// ToASCII normalises a label or domain name and converts it to A-labels.
+// Names that break the IDNA2008 rules or mix scripts in a label are
+// rejected. ASCII names are only lowercased and checked.
+func ToASCII(name string) (string, error) {
+	ascii, err := profile.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain name %q: %w", name, err)
+	}
+	if IsASCII(name) && !strings.Contains(ascii, "xn--") {
+		return ascii, nil
+	}
+	unicodeName, err := profile.ToUnicode(ascii)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain name %q: %w", name, err)
+	}
+	for _, label := range strings.Split(unicodeName, ".") {
+		if err := CheckScripts(label); err != nil {
+			return "", err
+		}
+	}
+	return ascii, nil
+}
+
+// ToUnicode converts the A-labels of a name to U-labels. Names that are not
+// valid IDNs are returned unchanged.
+func ToUnicode(name string) string {
+	if !strings.Contains(name, "xn--") {
+		return name
+	}
+	unicodeName, err := profile.ToUnicode(name)
+	if err != nil {
+		return name
+	}
+	return unicodeName
+}
+
+// Display formats a name for messages: IDNs as "münchen.de
+// (xn--mnchen-3ya.de)", other names as they are
+func Display(name string) string {
+	if unicodeName := ToUnicode(name); unicodeName != name {
+		return unicodeName + " (" + name + ")"
+	}
+	return name
+}
+
+// IsASCII reports whether s contains only ASCII characters
+func IsASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckRune checks that r may appear in a U-label as it is, i.e. that it is
+// valid on its own and not mapped to something else (like Ä to ä)
+func CheckRune(r rune) error {
+	ascii, err := profile.ToASCII(string(r))
+	if err != nil {
+		return fmt.Errorf("%q is not allowed in domain names", r)
+	}
+	if mapped := ToUnicode(ascii); mapped != string(r) {
+		return fmt.Errorf("%q is not allowed in domain names, use %q", r, mapped)
+	}
+	return nil
+}
+
+// Scripts returns the scripts used by s, without Common and Inherited
+// characters such as digits, hyphens and combining marks
+func Scripts(s string) []string {
+	seen := make(map[string]bool)
+	var scripts []string
+	for _, r := range s {
+		script := scriptOf(r)
+		if script == "" || seen[script] {
+			continue
+		}
+		seen[script] = true
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// CheckScripts rejects labels that mix scripts, such as a Latin name with a
+// Cyrillic "а" standing in for "a"
+func CheckScripts(label string) error {
+	scripts := Scripts(label)
+	if len(scripts) <= 1 {
+		return nil
+	}
+	for _, mix := range scriptMixes {
+		allowed := true
+		for _, script := range scripts {
+			if !slices.Contains(mix, script) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("label %q mixes scripts (%s)", label, strings.Join(scripts, ", "))
+}
+
+// scriptOf returns the script of r, or "" for Common and Inherited
+func scriptOf(r rune) string {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return "Latin"
+	case r < utf8.RuneSelf:
+		return ""
+	}
+	for _, name := range scriptNames {
+		if unicode.Is(unicode.Scripts[name], r) {
+			if name == "Common" || name == "Inherited" {
+				return ""
+			}
+			return name
+		}
+	}
+	return ""
+}
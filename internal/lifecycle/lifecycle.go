@@ -0,0 +1,159 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+// Stage is the position of a registered domain in the expiry lifecycle
+type Stage int
+
+const (
+	StageUnknown       Stage = iota // No registration data to judge from
+	StageActive                     // Registered and not expired
+	StageGrace                      // Expired, in the (auto-)renew grace period
+	StageRedemption                 // Deleted by the registrar, still restorable
+	StagePendingDelete              // Will be purged from the registry within days
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageActive:
+		return "ACTIVE"
+	case StageGrace:
+		return "GRACE"
+	case StageRedemption:
+		return "REDEMPTION"
+	case StagePendingDelete:
+		return "PENDING_DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Dropping reports whether a domain in this stage is on its way to deletion
+func (s Stage) Dropping() bool {
+	return s == StageGrace || s == StageRedemption || s == StagePendingDelete
+}
+
+// Lengths of the lifecycle periods of ICANN gTLDs. Many ccTLDs use shorter
+// ones, so drop dates are estimates to be confirmed closer to the date.
+const (
+	GracePeriod         = 45 * 24 * time.Hour
+	RedemptionPeriod    = 30 * 24 * time.Hour
+	PendingDeletePeriod = 5 * 24 * time.Hour
+)
+
+const dateLayout = "2006-01-02"
+
+// Info is the lifecycle classification of a registered domain
+type Info struct {
+	Domain   string
+	Stage    Stage
+	Expires  time.Time
+	DropDate time.Time // Estimated date the name becomes registrable again
+}
+
+// statusStages maps EPP (RFC 5731/3915), RDAP (RFC 8056) and common ccTLD
+// status values, lowercased with spaces, dashes and underscores removed
+var statusStages = map[string]Stage{
+	"pendingdelete":    StagePendingDelete,
+	"redemptionperiod": StageRedemption,
+	"redemption":       StageRedemption,
+	"pendingrestore":   StageRedemption,
+	"autorenewperiod":  StageGrace,
+	"graceperiod":      StageGrace,
+	"expired":          StageGrace,
+	"expire":           StageGrace,
+}
+
+// Classify derives the lifecycle stage and the estimated drop date of a
+// domain from its registration record as of now
+func Classify(domain string, registration *types.Registration, now time.Time) Info {
+	info := Info{Domain: domain}
+	if registration == nil {
+		return info
+	}
+	expires, updated := dateOf(registration.Expires), dateOf(registration.Updated)
+	info.Expires = expires
+
+	// EPP keeps pendingDelete set throughout the redemption period (RFC 3915),
+	// so redemption wins over pending delete
+	seen := make(map[Stage]bool)
+	for _, status := range registration.Statuses {
+		seen[statusStages[normalize(status)]] = true
+	}
+	switch {
+	case seen[StageRedemption]:
+		info.Stage = StageRedemption
+	case seen[StagePendingDelete]:
+		info.Stage = StagePendingDelete
+	case seen[StageGrace]:
+		info.Stage = StageGrace
+	default:
+		info.Stage = StageActive
+	}
+	if info.Stage == StageActive && !expires.IsZero() && expires.Before(now) {
+		info.Stage = StageGrace
+	}
+
+	// The last update of a domain in redemption or pending delete is usually
+	// the moment it entered that state
+	entered := func(fallback time.Time) time.Time {
+		if !updated.IsZero() && updated.After(expires) {
+			return updated
+		}
+		return fallback
+	}
+
+	switch info.Stage {
+	case StagePendingDelete:
+		info.DropDate = entered(now).Add(PendingDeletePeriod)
+	case StageRedemption:
+		fallback := now
+		if !expires.IsZero() {
+			fallback = expires.Add(GracePeriod)
+		}
+		info.DropDate = entered(fallback).Add(RedemptionPeriod + PendingDeletePeriod)
+	case StageGrace, StageActive:
+		if !expires.IsZero() {
+			info.DropDate = expires.Add(GracePeriod + RedemptionPeriod + PendingDeletePeriod)
+		} else if info.Stage == StageGrace {
+			info.DropDate = now.Add(GracePeriod + RedemptionPeriod + PendingDeletePeriod)
+		}
+	}
+
+	// A drop date that has passed means the name can be purged any moment
+	if info.Stage.Dropping() && info.DropDate.Before(now) {
+		info.DropDate = now
+	}
+	return info
+}
+
+// ReportLine formats the classification as a tab-separated line starting with
+// the drop date, so that sorting report lines orders them by drop date:
+//
+//	2026-11-02	PENDING_DELETE	abc.li	expires 2026-08-01
+func (i Info) ReportLine() string {
+	expires := "-"
+	if !i.Expires.IsZero() {
+		expires = i.Expires.Format(dateLayout)
+	}
+	return fmt.Sprintf("%s\t%s\t%s\texpires %s", i.DropDate.Format(dateLayout), i.Stage, i.Domain, expires)
+}
+
+// dateOf returns the date, or the zero time if the registry did not publish it
+func dateOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func normalize(status string) string {
+	status = strings.ToLower(status)
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(status)
+}
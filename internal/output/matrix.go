@@ -0,0 +1,129 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"domain_scanner/internal/idn"
+)
+
+// Matrix cells of a label under one TLD
+const (
+	CellFree      = "free"  // Available
+	CellWeakFree  = "free?" // Available below -min-confidence
+	CellTaken     = "taken" // Registered or reserved
+	CellUnknown   = "?"     // Unknown, rate limited or failed
+	cellUnchecked = "."
+)
+
+// Matrix collects the verdicts of a multi-TLD scan per label, so that the
+// TLDs under which a name is free can be read off one row
+type Matrix struct {
+	suffixes []string
+	cells    map[string]map[string]string // label -> suffix -> cell
+}
+
+// NewMatrix returns an empty matrix with one column per suffix
+func NewMatrix(suffixes []string) *Matrix {
+	return &Matrix{suffixes: suffixes, cells: make(map[string]map[string]string)}
+}
+
+// Set records the cell of a domain; domains under none of the suffixes are
+// ignored
+func (m *Matrix) Set(domain, cell string) {
+	suffix := SuffixOf(domain, m.suffixes)
+	if suffix == "" {
+		return
+	}
+	label := strings.TrimSuffix(domain, suffix)
+	row, ok := m.cells[label]
+	if !ok {
+		row = make(map[string]string, len(m.suffixes))
+		m.cells[label] = row
+	}
+	row[suffix] = cell
+}
+
+// Cells returns every recorded cell by domain, e.g. to save it in a checkpoint
+func (m *Matrix) Cells() map[string]string {
+	cells := make(map[string]string)
+	for label, row := range m.cells {
+		for suffix, cell := range row {
+			cells[label+suffix] = cell
+		}
+	}
+	return cells
+}
+
+// WriteFile writes the matrix as an aligned table, labels free under the
+// most TLDs first. IDN labels and TLDs are shown as U-labels.
+func (m *Matrix) WriteFile(path string) error {
+	labels := make([]string, 0, len(m.cells))
+	free := make(map[string]int, len(m.cells))
+	names := make(map[string]string, len(m.cells))
+	for label, row := range m.cells {
+		labels = append(labels, label)
+		names[label] = idn.ToUnicode(label)
+		for _, cell := range row {
+			if cell == CellFree {
+				free[label]++
+			}
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if free[labels[i]] != free[labels[j]] {
+			return free[labels[i]] > free[labels[j]]
+		}
+		return names[labels[i]] < names[labels[j]]
+	})
+	headers := make([]string, len(m.suffixes))
+	for i, suffix := range m.suffixes {
+		headers[i] = "." + idn.ToUnicode(strings.TrimPrefix(suffix, "."))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create matrix file: %w", err)
+	}
+	buffered := bufio.NewWriter(file)
+	table := tabwriter.NewWriter(buffered, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "# %s: available, %s: available with low confidence, %s: registered or reserved, %s: unknown, %s: not checked\n",
+		CellFree, CellWeakFree, CellTaken, CellUnknown, cellUnchecked)
+	fmt.Fprintf(table, "label\t%s\tfree\n", strings.Join(headers, "\t"))
+	for _, label := range labels {
+		row := m.cells[label]
+		cells := make([]string, len(m.suffixes))
+		for i, suffix := range m.suffixes {
+			cells[i] = row[suffix]
+			if cells[i] == "" {
+				cells[i] = cellUnchecked
+			}
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\n", names[label], strings.Join(cells, "\t"), free[label])
+	}
+	if err := table.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// SuffixOf returns the longest of suffixes that domain ends with, or "" if
+// none does
+func SuffixOf(domain string, suffixes []string) string {
+	best := ""
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(domain, suffix) && len(suffix) > len(best) {
+			best = suffix
+		}
+	}
+	return best
+}
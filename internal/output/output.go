@@ -0,0 +1,242 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/idn"
+	"domain_scanner/internal/types"
+)
+
+// Formats lists the supported structured output formats
+var Formats = []string{"ndjson", "json", "csv"}
+
+// Writer receives every domain result of a scan
+type Writer interface {
+	Write(result types.DomainResult) error
+	Close() error
+}
+
+// Record is the serialized form of a domain result. Domain is the name as
+// queried, with IDNs as A-labels; Unicode holds their U-label form.
+type Record struct {
+	Domain     string       `json:"domain"`
+	Unicode    string       `json:"unicode,omitempty"`
+	Status     types.Status `json:"status"`
+	Confidence float64      `json:"confidence"`
+	Verified   bool         `json:"verified"`
+	Reason     string       `json:"reason,omitempty"`
+	Signatures []string     `json:"signatures"`
+	Server     string       `json:"server,omitempty"`
+	LatencyMs  int64        `json:"latency_ms"`
+	CheckedAt  time.Time    `json:"checked_at"`
+	Cached     bool         `json:"cached"`
+	Error      string       `json:"error,omitempty"`
+
+	Registration *types.Registration `json:"registration,omitempty"`
+}
+
+var csvHeader = []string{
+	"domain", "status", "confidence", "verified", "reason", "signatures", "server", "latency_ms", "checked_at", "cached", "error",
+	"registrar", "created", "updated", "expires", "statuses", "nameservers", "dnssec",
+	"unicode",
+}
+
+// NewRecord converts a domain result into its serialized form
+func NewRecord(result types.DomainResult) Record {
+	record := Record{
+		Domain:     result.Domain,
+		Status:     result.Status,
+		Confidence: result.Confidence,
+		Verified:   result.Verified,
+		Reason:     result.Reason,
+		Signatures: result.Signatures,
+		Server:     result.Server,
+		LatencyMs:  result.Latency.Milliseconds(),
+		CheckedAt:  result.CheckedAt,
+		Cached:     result.Cached,
+
+		Registration: result.Registration,
+	}
+	if unicodeName := idn.ToUnicode(result.Domain); unicodeName != result.Domain {
+		record.Unicode = unicodeName
+	}
+	if record.Signatures == nil {
+		record.Signatures = []string{}
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	return record
+}
+
+// NewWriter creates a writer for the given format writing to path. With
+// appendTo, NDJSON and CSV records are added to an existing file (a resumed
+// scan); JSON is a single array and cannot be appended to.
+func NewWriter(format, path string, appendTo bool) (Writer, error) {
+	format = strings.ToLower(format)
+	if format != "ndjson" && format != "json" && format != "csv" {
+		return nil, fmt.Errorf("unknown output format %q (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+	if appendTo && format == "json" {
+		return nil, fmt.Errorf("cannot append to a JSON result file, use ndjson or csv")
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	buffered := bufio.NewWriter(file)
+
+	switch format {
+	case "ndjson":
+		return &ndjsonWriter{file: file, buf: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case "json":
+		return &jsonWriter{file: file, buf: buffered}, nil
+	default:
+		w := &csvWriter{file: file, csv: csv.NewWriter(file)}
+		if info.Size() > 0 {
+			return w, nil // The header is already there
+		}
+		if err := w.csv.Write(csvHeader); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write output file: %w", err)
+		}
+		return w, nil
+	}
+}
+
+// ndjsonWriter streams one JSON object per line as results arrive
+type ndjsonWriter struct {
+	file    *os.File
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(result types.DomainResult) error {
+	if err := w.encoder.Encode(NewRecord(result)); err != nil {
+		return err
+	}
+	// Flush every line so the file can be tailed during the scan
+	return w.buf.Flush()
+}
+
+func (w *ndjsonWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// jsonWriter collects the results and writes them as one array on Close
+type jsonWriter struct {
+	file    *os.File
+	buf     *bufio.Writer
+	records []Record
+}
+
+func (w *jsonWriter) Write(result types.DomainResult) error {
+	w.records = append(w.records, NewRecord(result))
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	records := w.records
+	if records == nil {
+		records = []Record{}
+	}
+	encoder := json.NewEncoder(w.buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(records); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// csvWriter streams one row per result after a header row
+type csvWriter struct {
+	file *os.File
+	csv  *csv.Writer
+}
+
+func (w *csvWriter) Write(result types.DomainResult) error {
+	record := NewRecord(result)
+	row := []string{
+		record.Domain,
+		record.Status.String(),
+		strconv.FormatFloat(record.Confidence, 'f', 2, 64),
+		strconv.FormatBool(record.Verified),
+		record.Reason,
+		strings.Join(record.Signatures, ","),
+		record.Server,
+		strconv.FormatInt(record.LatencyMs, 10),
+		formatTime(record.CheckedAt),
+		strconv.FormatBool(record.Cached),
+		record.Error,
+	}
+	if registration := record.Registration; registration != nil {
+		row = append(row,
+			registration.Registrar,
+			formatDate(registration.Created),
+			formatDate(registration.Updated),
+			formatDate(registration.Expires),
+			strings.Join(registration.Statuses, ","),
+			strings.Join(registration.Nameservers, ","),
+			registration.DNSSEC,
+		)
+	} else {
+		row = append(row, "", "", "", "", "", "", "")
+	}
+	row = append(row, record.Unicode)
+	if err := w.csv.Write(row); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// formatTime formats t as RFC 3339, leaving zero times empty
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatDate formats a registration date the registry may not have published
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
@@ -0,0 +1,50 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"domain_scanner/internal/types"
+)
+
+func TestResumedWriterAppends(t *testing.T) {
+	for _, format := range []string{"ndjson", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results."+format)
+			for i, domain := range []string{"abc.li", "abd.li"} {
+				w, err := NewWriter(format, path, i > 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Write(types.DomainResult{Domain: domain, Status: types.StatusAvailable}); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			want := 2
+			if format == "csv" {
+				want = 3 // One header
+			}
+			if len(lines) != want {
+				t.Errorf("got %d lines, want %d:\n%s", len(lines), want, data)
+			}
+			if !strings.Contains(string(data), "abc.li") || !strings.Contains(string(data), "abd.li") {
+				t.Errorf("the results before the resume are lost:\n%s", data)
+			}
+		})
+	}
+
+	if _, err := NewWriter("json", filepath.Join(t.TempDir(), "results.json"), true); err == nil {
+		t.Error("appending to a JSON array should be rejected")
+	}
+}
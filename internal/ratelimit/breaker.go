@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds how long a half-open breaker waits for its probe query
+// to report back before letting another query through
+const probeTimeout = time.Minute
+
+// Breakers keeps a circuit breaker per endpoint. A breaker opens when the
+// server signals rate limiting or fails threshold times in a row, rejects
+// queries for a cooldown and then lets a single probe through. Each time the
+// probe fails the cooldown doubles, up to maxCooldown.
+type Breakers struct {
+	mu          sync.Mutex
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	servers     map[string]*breaker
+
+	// OnChange, if set, is called whenever a breaker opens or closes again
+	OnChange func(endpoint string, open bool, cooldown time.Duration, reason string)
+}
+
+type breaker struct {
+	failures   int       // Consecutive failures while closed
+	trips      int       // Consecutive openings without a successful query
+	openUntil  time.Time // Zero while closed
+	probeUntil time.Time // A probe is in flight until then
+}
+
+// OpenBreaker describes a paused endpoint
+type OpenBreaker struct {
+	Endpoint  string
+	Remaining time.Duration
+}
+
+// NewBreakers returns breakers opening after threshold consecutive failures
+func NewBreakers(threshold int, cooldown, maxCooldown time.Duration) *Breakers {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if maxCooldown < cooldown {
+		maxCooldown = cooldown
+	}
+	return &Breakers{
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: maxCooldown,
+		servers:     make(map[string]*breaker),
+	}
+}
+
+func (b *Breakers) get(endpoint string) *breaker {
+	endpoint = strings.ToLower(endpoint)
+	br, ok := b.servers[endpoint]
+	if !ok {
+		br = &breaker{}
+		b.servers[endpoint] = br
+	}
+	return br
+}
+
+// Allow reports whether a query to endpoint may be sent. If not, it returns
+// how long the endpoint stays paused.
+func (b *Breakers) Allow(endpoint string) (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.get(endpoint)
+	if br.openUntil.IsZero() {
+		return 0, true
+	}
+	now := time.Now()
+	if now.Before(br.openUntil) {
+		return br.openUntil.Sub(now), false
+	}
+	// Half open: a single probe decides whether the server has recovered
+	if now.Before(br.probeUntil) {
+		return br.probeUntil.Sub(now), false
+	}
+	br.probeUntil = now.Add(probeTimeout)
+	return 0, true
+}
+
+// Success records an answer from endpoint and closes its breaker
+func (b *Breakers) Success(endpoint string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	br := b.get(endpoint)
+	wasOpen := !br.openUntil.IsZero()
+	*br = breaker{}
+	onChange := b.OnChange
+	b.mu.Unlock()
+
+	if wasOpen && onChange != nil {
+		onChange(endpoint, false, 0, "answering again")
+	}
+}
+
+// Failure records a failed query (timeout, connection error). The breaker
+// opens once threshold failures happened in a row, or right away for a probe.
+func (b *Breakers) Failure(endpoint, reason string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	br := b.get(endpoint)
+	if !br.openUntil.IsZero() && time.Now().Before(br.openUntil) {
+		b.mu.Unlock()
+		return // Already paused
+	}
+	br.failures++
+	if br.openUntil.IsZero() && br.failures < b.threshold {
+		b.mu.Unlock()
+		return
+	}
+	b.open(endpoint, br, reason)
+}
+
+// Trip opens the breaker of endpoint immediately, e.g. when the server says
+// it is rate limiting us
+func (b *Breakers) Trip(endpoint, reason string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	br := b.get(endpoint)
+	if !br.openUntil.IsZero() && time.Now().Before(br.openUntil) {
+		b.mu.Unlock()
+		return // Already paused, other workers' queries were in flight
+	}
+	b.open(endpoint, br, reason)
+}
+
+// open must be called with b.mu held and releases it
+func (b *Breakers) open(endpoint string, br *breaker, reason string) {
+	cooldown := b.cooldown << br.trips
+	if cooldown > b.maxCooldown || cooldown <= 0 {
+		cooldown = b.maxCooldown
+	}
+	br.trips++
+	br.failures = 0
+	br.openUntil = time.Now().Add(cooldown)
+	br.probeUntil = time.Time{}
+	onChange := b.OnChange
+	b.mu.Unlock()
+
+	if onChange != nil {
+		onChange(endpoint, true, cooldown, reason)
+	}
+}
+
+// Remaining returns how long endpoint stays paused, zero if it accepts queries
+func (b *Breakers) Remaining(endpoint string) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br := b.get(endpoint)
+	if br.openUntil.IsZero() {
+		return 0
+	}
+	if remaining := time.Until(br.openUntil); remaining > 0 {
+		return remaining
+	}
+	if remaining := time.Until(br.probeUntil); remaining > 0 {
+		return remaining // Waiting for the probe's answer
+	}
+	return 0
+}
+
+// Open lists the endpoints currently paused, sorted by name
+func (b *Breakers) Open() []OpenBreaker {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var open []OpenBreaker
+	now := time.Now()
+	for endpoint, br := range b.servers {
+		if !br.openUntil.IsZero() && now.Before(br.openUntil) {
+			open = append(open, OpenBreaker{Endpoint: endpoint, Remaining: br.openUntil.Sub(now)})
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].Endpoint < open[j].Endpoint })
+	return open
+}
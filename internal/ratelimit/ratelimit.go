@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket allowing rate queries per second on average and
+// bursts of up to burst queries
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket. A rate of zero or less never blocks.
+func NewBucket(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait takes a token, blocking until one is available or ctx is canceled
+func (b *Bucket) Wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return ctx.Err()
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// Reserve the token right away, so waiters are served in arrival order
+	b.tokens--
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Hand the reservation back to the queries still waiting
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Limit is the rate and burst of one endpoint
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter hands out one bucket per endpoint (a WHOIS or RDAP server), shared
+// by every worker, so the query rate per server does not grow with the
+// number of workers
+type Limiter struct {
+	mu        sync.Mutex
+	fallback  Limit
+	overrides map[string]Limit
+	buckets   map[string]*Bucket
+}
+
+// New returns a limiter applying the default limit to every endpoint
+// without an override
+func New(fallback Limit, overrides map[string]Limit) *Limiter {
+	l := &Limiter{
+		fallback:  fallback,
+		overrides: make(map[string]Limit, len(overrides)),
+		buckets:   make(map[string]*Bucket),
+	}
+	for endpoint, limit := range overrides {
+		l.overrides[strings.ToLower(endpoint)] = limit
+	}
+	return l
+}
+
+// Wait blocks until a query to endpoint is allowed or ctx is canceled
+func (l *Limiter) Wait(ctx context.Context, endpoint string) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	return l.bucket(endpoint).Wait(ctx)
+}
+
+func (l *Limiter) bucket(endpoint string) *Bucket {
+	endpoint = strings.ToLower(endpoint)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if bucket, ok := l.buckets[endpoint]; ok {
+		return bucket
+	}
+	limit, ok := l.overrides[endpoint]
+	if !ok {
+		limit = l.fallback
+	}
+	bucket := NewBucket(limit.Rate, limit.Burst)
+	l.buckets[endpoint] = bucket
+	return bucket
+}
+
+// ParseOverrides parses per-endpoint limits of the form
+// "whois.nic.li=0.5:1,rdap.nic.ch=2". A missing burst uses defaultBurst.
+func ParseOverrides(spec string, defaultBurst int) (map[string]Limit, error) {
+	overrides := make(map[string]Limit)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		endpoint, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(endpoint) == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, expected <server>=<qps>[:<burst>]", item)
+		}
+		rateText, burstText, hasBurst := strings.Cut(value, ":")
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateText), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate in %q", item)
+		}
+		limit := Limit{Rate: rate, Burst: defaultBurst}
+		if hasBurst {
+			limit.Burst, err = strconv.Atoi(strings.TrimSpace(burstText))
+			if err != nil || limit.Burst < 1 {
+				return nil, fmt.Errorf("invalid burst in %q", item)
+			}
+		}
+		overrides[strings.TrimSpace(endpoint)] = limit
+	}
+	return overrides, nil
+}
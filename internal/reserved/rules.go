@@ -4,6 +4,8 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+
+	"domain_scanner/internal/idn"
 )
 
 var (
@@ -95,11 +97,13 @@ func initTechPrefixesMap() {
 	}
 }
 
-// IsReservedByPattern checks if a domain is reserved based on common patterns
+// IsReservedByPattern checks if a domain is reserved based on common patterns.
+// IDNs are checked in their Unicode form; the short name and technical
+// patterns describe ASCII names and do not apply to them.
 func IsReservedByPattern(domain string) bool {
 	initReservedPatterns()
 
-	domainLower := strings.ToLower(domain)
+	domainLower := normalize(domain)
 
 	// Remove TLD to check only the domain name part
 	parts := strings.Split(domainLower, ".")
@@ -112,6 +116,9 @@ func IsReservedByPattern(domain string) bool {
 	if reservedWordsMap[domainName] {
 		return true
 	}
+	if !idn.IsASCII(domainName) {
+		return false
+	}
 
 	// Check compiled regex patterns
 	for _, re := range compiledPatterns {
@@ -134,6 +141,15 @@ func IsReservedByPattern(domain string) bool {
 	return false
 }
 
+// normalize lowercases a domain and converts its A-labels to U-labels, so
+// that münchen.de, MÜNCHEN.DE and xn--mnchen-3ya.de are checked alike
+func normalize(domain string) string {
+	if ascii, err := idn.ToASCII(domain); err == nil {
+		return idn.ToUnicode(ascii)
+	}
+	return strings.ToLower(domain)
+}
+
 // checkTechnicalPattern checks for technical terms with optional numbers
 func checkTechnicalPattern(name string) bool {
 	// Direct match first
@@ -232,7 +248,7 @@ func initTLDRulesCache() {
 func IsReservedByTLD(domain string) bool {
 	initTLDRulesCache()
 
-	domainLower := strings.ToLower(domain)
+	domainLower := normalize(domain)
 
 	// Extract TLD from domain
 	parts := strings.Split(domainLower, ".")
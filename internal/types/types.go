@@ -1,8 +1,122 @@
 package types
 
+import (
+	"fmt"
+	"time"
+)
+
+// Status is the verdict for a single domain
+type Status int
+
+const (
+	StatusUnknown     Status = iota // The checks could not reach a verdict
+	StatusAvailable                 // The registry reports the name as free
+	StatusRegistered                // The name is registered
+	StatusReserved                  // The name is reserved or blocked by the registry
+	StatusPremium                   // The name is held back as a premium name
+	StatusRateLimited               // The registry refused to answer due to rate limiting
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusAvailable:
+		return "AVAILABLE"
+	case StatusRegistered:
+		return "REGISTERED"
+	case StatusReserved:
+		return "RESERVED"
+	case StatusPremium:
+		return "PREMIUM"
+	case StatusRateLimited:
+		return "RATE_LIMITED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseStatus is the inverse of Status.String
+func ParseStatus(s string) (Status, bool) {
+	for status := StatusUnknown; status <= StatusRateLimited; status++ {
+		if status.String() == s {
+			return status, true
+		}
+	}
+	return StatusUnknown, false
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(text []byte) error {
+	status, ok := ParseStatus(string(text))
+	if !ok {
+		return fmt.Errorf("unknown status %q", text)
+	}
+	*s = status
+	return nil
+}
+
+// Taken reports whether the status means the name cannot be registered
+func (s Status) Taken() bool {
+	return s == StatusRegistered || s == StatusReserved || s == StatusPremium
+}
+
+// Conclusive reports whether the status is a definite answer rather than a failure to query
+func (s Status) Conclusive() bool {
+	return s != StatusUnknown && s != StatusRateLimited
+}
+
+// Candidate is a domain produced by the generator. Index is its position in
+// the generator's keyspace and increases monotonically, so it can be used as
+// a resume cursor.
+type Candidate struct {
+	Domain string
+	Index  int64
+}
+
 type DomainResult struct {
-	Domain     string
-	Available  bool
-	Error      error
-	Signatures []string
+	Domain       string
+	Index        int64 // Index of the candidate the result belongs to
+	Status       Status
+	Reason       string     // Human-readable explanation of the verdict
+	Evidence     []Evidence // Everything the checkers observed
+	Error        error
+	Signatures   []string
+	Server       string        // Server that gave the deciding answer, if any
+	Latency      time.Duration // Time spent checking the domain
+	CheckedAt    time.Time
+	Cached       bool          // The verdict was taken from the result cache
+	Confidence   float64       // How strongly the evidence supports the verdict, 0 to 1
+	Verified     bool          // The verdict went through the dual-source verification
+	Registration *Registration // Registration data from WHOIS or RDAP, if any
+}
+
+// Evidence is what a single check backend observed about a domain
+type Evidence struct {
+	Checker      string // Name of the checker that produced the evidence
+	Signature    string // Signature to report when the evidence shows the name is taken
+	Status       Status // Verdict suggested by this evidence, StatusUnknown if inconclusive
+	Conclusive   bool   // Later checkers cannot change the outcome
+	Server       string // WHOIS server or RDAP service that answered, if any
+	Detail       string
+	Matched      string // Indicator or rule in the answer that decided the evidence
+	Raw          string // Raw answer: WHOIS text, RDAP JSON or DNS records
+	Err          error
+	Latency      time.Duration
+	Registration *Registration // Registration data extracted from the answer
+}
+
+// Registration is the structured registration record of a domain, as
+// extracted from a WHOIS or RDAP answer. Fields the registry did not
+// publish are left empty.
+type Registration struct {
+	Registrar   string     `json:"registrar,omitempty"`
+	Created     *time.Time `json:"created,omitempty"`
+	Updated     *time.Time `json:"updated,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	Statuses    []string   `json:"statuses,omitempty"` // EPP status codes or the registry's own status words
+	Nameservers []string   `json:"nameservers,omitempty"`
+	DNSSEC      string     `json:"dnssec,omitempty"` // "signed", "unsigned" or empty if not published
+	Source      string     `json:"source,omitempty"` // WHOIS server or RDAP service the record came from
 }
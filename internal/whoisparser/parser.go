@@ -0,0 +1,248 @@
+package whoisparser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+type field int
+
+const (
+	fieldNone field = iota
+	fieldRegistrar
+	fieldCreated
+	fieldUpdated
+	fieldExpires
+	fieldStatus
+	fieldNameserver
+	fieldDNSSEC
+	fieldKeyset
+)
+
+// fieldKeys maps the lowercased keys used by ICANN-style registries, DENIC
+// (.de), SWITCH (.ch/.li), CZ.NIC (.cz), CoCCA (.cx), Nominet (.uk) and other
+// common formats to the fields they hold
+var fieldKeys = map[string]field{
+	"registrar":              fieldRegistrar,
+	"registrar name":         fieldRegistrar,
+	"sponsoring registrar":   fieldRegistrar,
+	"registrar organization": fieldRegistrar,
+
+	"creation date":            fieldCreated,
+	"created":                  fieldCreated,
+	"created on":               fieldCreated,
+	"created date":             fieldCreated,
+	"registered":               fieldCreated,
+	"registered on":            fieldCreated,
+	"registration date":        fieldCreated,
+	"registration time":        fieldCreated,
+	"domain registration date": fieldCreated,
+	"first registration date":  fieldCreated,
+	"domain record activated":  fieldCreated,
+	"record created":           fieldCreated,
+
+	"updated date":               fieldUpdated,
+	"updated":                    fieldUpdated,
+	"updated on":                 fieldUpdated,
+	"last updated":               fieldUpdated,
+	"last updated on":            fieldUpdated,
+	"changed":                    fieldUpdated,
+	"last modified":              fieldUpdated,
+	"modified":                   fieldUpdated,
+	"last update":                fieldUpdated,
+	"domain record last updated": fieldUpdated,
+
+	"registry expiry date":                   fieldExpires,
+	"registrar registration expiration date": fieldExpires,
+	"expiry date":                            fieldExpires,
+	"expiration date":                        fieldExpires,
+	"expires":                                fieldExpires,
+	"expires on":                             fieldExpires,
+	"expire":                                 fieldExpires,
+	"expire date":                            fieldExpires,
+	"paid-till":                              fieldExpires,
+	"expiration time":                        fieldExpires,
+	"domain expiration date":                 fieldExpires,
+	"renewal date":                           fieldExpires,
+
+	"domain status":       fieldStatus,
+	"status":              fieldStatus,
+	"state":               fieldStatus,
+	"registration status": fieldStatus,
+
+	"name server":  fieldNameserver,
+	"name servers": fieldNameserver,
+	"nameserver":   fieldNameserver,
+	"nameservers":  fieldNameserver,
+	"nserver":      fieldNameserver,
+
+	"dnssec":        fieldDNSSEC,
+	"dnssec status": fieldDNSSEC,
+	"keyset":        fieldKeyset,
+}
+
+// keyLine matches "Key: value" as well as "Key:" opening a section whose
+// values follow on the next lines (SWITCH, Nominet)
+var keyLine = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9 ./()_-]*?)\s*:\s*(.*)$`)
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"2006.01.02 15:04:05",
+	"2006.01.02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"02-Jan-2006 15:04:05",
+	"02-Jan-2006",
+	"02-January-2006",
+	"January 2 2006",
+	"2 January 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	"20060102",
+}
+
+// Parse extracts the registration record from a raw WHOIS answer. When the
+// answer holds several records (a thin registry followed by the registrar),
+// the first value found for each single-valued field wins. It returns nil if
+// the answer contains none of the known fields.
+func Parse(raw string) *types.Registration {
+	record := &types.Registration{}
+	found := false
+	section := fieldNone
+
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			section = fieldNone
+			continue
+		}
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ">>>") {
+			continue
+		}
+
+		if match := keyLine.FindStringSubmatch(line); match != nil && !strings.HasPrefix(match[2], "//") {
+			key := strings.ToLower(strings.Join(strings.Fields(match[1]), " "))
+			value := strings.TrimSpace(match[2])
+			section = fieldNone
+			if value == "" {
+				section = fieldKeys[key]
+				continue
+			}
+			if apply(record, fieldKeys[key], value) {
+				found = true
+			}
+			continue
+		}
+
+		// A value line inside a section opened by "Key:"
+		if section != fieldNone && apply(record, section, line) {
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return record
+}
+
+// apply stores a value in the record and reports whether it was used
+func apply(record *types.Registration, f field, value string) bool {
+	switch f {
+	case fieldRegistrar:
+		if record.Registrar == "" {
+			record.Registrar = value
+			return true
+		}
+	case fieldCreated:
+		return setDate(&record.Created, value)
+	case fieldUpdated:
+		return setDate(&record.Updated, value)
+	case fieldExpires:
+		return setDate(&record.Expires, value)
+	case fieldStatus:
+		// ICANN style: "clientTransferProhibited https://icann.org/epp#..."
+		if i := strings.Index(value, "http"); i > 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		for _, existing := range record.Statuses {
+			if strings.EqualFold(existing, value) {
+				return false
+			}
+		}
+		record.Statuses = append(record.Statuses, value)
+		return true
+	case fieldNameserver:
+		// CZ.NIC and others append the glue: "ns1.example.cz (192.0.2.1)"
+		host := strings.ToLower(strings.TrimSuffix(strings.Fields(value)[0], "."))
+		for _, existing := range record.Nameservers {
+			if existing == host {
+				return false
+			}
+		}
+		record.Nameservers = append(record.Nameservers, host)
+		return true
+	case fieldDNSSEC:
+		if record.DNSSEC == "" {
+			if dnssec := parseDNSSEC(value); dnssec != "" {
+				record.DNSSEC = dnssec
+				return true
+			}
+		}
+	case fieldKeyset:
+		// CZ.NIC links a keyset only to signed domains
+		record.DNSSEC = "signed"
+		return true
+	}
+	return false
+}
+
+func parseDNSSEC(value string) string {
+	value = strings.ToLower(value)
+	switch {
+	case strings.Contains(value, "unsigned"), strings.Contains(value, "not signed"),
+		value == "n", value == "no", value == "inactive", value == "false":
+		return "unsigned"
+	case strings.Contains(value, "signed"), value == "y", value == "yes", value == "active", value == "true":
+		return "signed"
+	}
+	return ""
+}
+
+// setDate parses value into *target unless it is already set
+func setDate(target **time.Time, value string) bool {
+	if *target != nil {
+		return false
+	}
+	if t, ok := parseDate(value); ok {
+		*target = &t
+		return true
+	}
+	return false
+}
+
+func parseDate(value string) (time.Time, bool) {
+	// Drop trailing annotations such as "(UTC)" or "UTC"
+	if i := strings.Index(value, " ("); i > 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), " UTC"))
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
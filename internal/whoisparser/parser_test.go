@@ -0,0 +1,206 @@
+package whoisparser
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"domain_scanner/internal/types"
+)
+
+const icannAnswer = `   Domain Name: EXAMPLE.COM
+   Registry Domain ID: 2336799_DOMAIN_COM-VRSN
+   Registrar WHOIS Server: whois.iana.org
+   Registrar URL: http://res-dom.iana.org
+   Updated Date: 2024-08-14T07:01:34Z
+   Creation Date: 1995-08-14T04:00:00Z
+   Registry Expiry Date: 2025-08-13T04:00:00Z
+   Registrar: RESERVED-Internet Assigned Numbers Authority
+   Registrar IANA ID: 376
+   Domain Status: clientDeleteProhibited https://icann.org/epp#clientDeleteProhibited
+   Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited
+   Name Server: A.IANA-SERVERS.NET
+   Name Server: B.IANA-SERVERS.NET
+   DNSSEC: signedDelegation
+>>> Last update of whois database: 2024-09-01T10:00:00Z <<<
+`
+
+// Registrar WHOIS servers often write numeric offsets without a colon
+const registrarAnswer = `Domain Name: example.net
+Updated Date: 2025-03-04T05:06:07.000+0000
+Creation Date: 2001-02-03T04:05:06+0000
+Registrar Registration Expiration Date: 2026-01-02T03:04:05+0000
+Registrar: Example Registrar, LLC
+Domain Status: redemptionPeriod https://icann.org/epp#redemptionPeriod
+Name Server: ns1.example.net
+DNSSEC: unsigned
+`
+
+const denicAnswer = `% Restricted rights.
+%
+% Terms and Conditions of Use
+
+Domain: example.de
+Nserver: a.iana-servers.net
+Nserver: b.iana-servers.net
+Status: connect
+Changed: 2018-03-12T21:44:25+01:00
+`
+
+const switchAnswer = `Domain name:
+example.ch
+
+Holder of domain name:
+Example AG
+Bahnhofstrasse 1
+8001 Zürich
+
+Registrar:
+Example Registrar AG
+
+First registration date:
+1998-05-12
+
+DNSSEC:N
+
+Name servers:
+ns1.example.ch
+ns2.example.ch
+`
+
+const nominetAnswer = `
+    Domain name:
+        example.co.uk
+
+    Registrar:
+        Example Ltd [Tag = EXAMPLE]
+        URL: https://www.example.com
+
+    Relevant dates:
+        Registered on: 26-Aug-1996
+        Expiry date:  26-Aug-2026
+        Last updated:  25-Jul-2024
+
+    Registration status:
+        Registered until expiry date.
+
+    Name servers:
+        ns1.example.net
+        ns2.example.net
+
+    WHOIS lookup made at 10:00:00 01-Sep-2024
+`
+
+const cznicAnswer = `%  (c) 2006-2024 CZ.NIC, z.s.p.o.
+
+domain:       example.cz
+registrant:   SB:EXAMPLE
+nsset:        NSS:EXAMPLE:1
+keyset:       KEY:EXAMPLE
+registrar:    REG-EXAMPLE
+status:       Sponsoring registrar change forbidden
+registered:   15.03.2001 10:20:00
+changed:      20.05.2024 09:15:30
+expire:       15.03.2027
+
+nsset:        NSS:EXAMPLE:1
+nserver:      ns1.example.cz (192.0.2.1)
+nserver:      ns2.example.cz
+`
+
+func date(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	t = t.UTC()
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   types.Registration
+	}{
+		{name: "ICANN", answer: icannAnswer, want: types.Registration{
+			Registrar:   "RESERVED-Internet Assigned Numbers Authority",
+			Created:     date("1995-08-14T04:00:00Z"),
+			Updated:     date("2024-08-14T07:01:34Z"),
+			Expires:     date("2025-08-13T04:00:00Z"),
+			Statuses:    []string{"clientDeleteProhibited", "clientTransferProhibited"},
+			Nameservers: []string{"a.iana-servers.net", "b.iana-servers.net"},
+			DNSSEC:      "signed",
+		}},
+		{name: "registrar offsets", answer: registrarAnswer, want: types.Registration{
+			Registrar:   "Example Registrar, LLC",
+			Created:     date("2001-02-03T04:05:06Z"),
+			Updated:     date("2025-03-04T05:06:07Z"),
+			Expires:     date("2026-01-02T03:04:05Z"),
+			Statuses:    []string{"redemptionPeriod"},
+			Nameservers: []string{"ns1.example.net"},
+			DNSSEC:      "unsigned",
+		}},
+		{name: "DENIC", answer: denicAnswer, want: types.Registration{
+			Updated:     date("2018-03-12T20:44:25Z"),
+			Statuses:    []string{"connect"},
+			Nameservers: []string{"a.iana-servers.net", "b.iana-servers.net"},
+		}},
+		{name: "SWITCH", answer: switchAnswer, want: types.Registration{
+			Registrar:   "Example Registrar AG",
+			Created:     date("1998-05-12T00:00:00Z"),
+			Nameservers: []string{"ns1.example.ch", "ns2.example.ch"},
+			DNSSEC:      "unsigned",
+		}},
+		{name: "Nominet", answer: nominetAnswer, want: types.Registration{
+			Registrar:   "Example Ltd [Tag = EXAMPLE]",
+			Created:     date("1996-08-26T00:00:00Z"),
+			Updated:     date("2024-07-25T00:00:00Z"),
+			Expires:     date("2026-08-26T00:00:00Z"),
+			Statuses:    []string{"Registered until expiry date."},
+			Nameservers: []string{"ns1.example.net", "ns2.example.net"},
+		}},
+		{name: "CZ.NIC", answer: cznicAnswer, want: types.Registration{
+			Registrar:   "REG-EXAMPLE",
+			Created:     date("2001-03-15T10:20:00Z"),
+			Updated:     date("2024-05-20T09:15:30Z"),
+			Expires:     date("2027-03-15T00:00:00Z"),
+			Statuses:    []string{"Sponsoring registrar change forbidden"},
+			Nameservers: []string{"ns1.example.cz", "ns2.example.cz"},
+			DNSSEC:      "signed",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.answer)
+			if got == nil {
+				t.Fatal("Parse found no fields")
+			}
+			want := test.want
+			if got.Registrar != want.Registrar {
+				t.Errorf("Registrar = %q, want %q", got.Registrar, want.Registrar)
+			}
+			for _, d := range []struct {
+				name      string
+				got, want *time.Time
+			}{{"Created", got.Created, want.Created}, {"Updated", got.Updated, want.Updated}, {"Expires", got.Expires, want.Expires}} {
+				if (d.got == nil) != (d.want == nil) || (d.got != nil && !d.got.Equal(*d.want)) {
+					t.Errorf("%s = %v, want %v", d.name, d.got, d.want)
+				}
+			}
+			if !slices.Equal(got.Statuses, want.Statuses) {
+				t.Errorf("Statuses = %q, want %q", got.Statuses, want.Statuses)
+			}
+			if !slices.Equal(got.Nameservers, want.Nameservers) {
+				t.Errorf("Nameservers = %q, want %q", got.Nameservers, want.Nameservers)
+			}
+			if got.DNSSEC != want.DNSSEC {
+				t.Errorf("DNSSEC = %q, want %q", got.DNSSEC, want.DNSSEC)
+			}
+		})
+	}
+
+	if got := Parse("No match for \"EXAMPLE.COM\".\n"); got != nil {
+		t.Errorf("Parse of an answer without fields = %+v, want nil", got)
+	}
+}
@@ -1,22 +1,257 @@
 package worker
 
 import (
+	"context"
+	"sync"
 	"time"
 
+	"domain_scanner/internal/cache"
 	"domain_scanner/internal/domain"
 	"domain_scanner/internal/types"
 )
 
-func Worker(id int, jobs <-chan string, results chan<- types.DomainResult, delay time.Duration) {
-	for domainName := range jobs {
-		available, err := domain.CheckDomainAvailability(domainName)
-		signatures, _ := domain.CheckDomainSignatures(domainName)
-		results <- types.DomainResult{
-			Domain:     domainName,
-			Available:  available,
-			Error:      err,
-			Signatures: signatures,
-		}
-		time.Sleep(delay)
+// Worker checks domains from jobs until the channel is closed. Canceling ctx
+// aborts the network calls of the check in progress and stops the worker.
+// Domains found in resultCache (which may be nil) are answered without any
+// network query.
+func Worker(ctx context.Context, id int, jobs <-chan types.Candidate, results chan<- types.DomainResult, delay time.Duration, pipeline *domain.Pipeline, resultCache *cache.DomainCache) {
+	for job := range jobs {
+		if result, found := cachedResult(resultCache, job); found {
+			results <- result
+			continue // No query was made, so no need to wait
+		}
+
+		// A single pass gathers the evidence for both the verdict and the signatures
+		result := pipeline.Check(ctx, job.Domain)
+		result.Index = job.Index
+		storeResult(ctx, resultCache, &result)
+		results <- result
+
+		if !pause(ctx, delay) {
+			return
+		}
+	}
+}
+
+// PrefilterWorker runs the cheap first stage of a split pipeline. Domains the
+// prefilter already decides (e.g. because they have a DNS delegation) go
+// straight to results, all others are handed to the next stage.
+func PrefilterWorker(ctx context.Context, id int, jobs <-chan types.Candidate, results chan<- types.DomainResult, next chan<- types.DomainResult, pipeline *domain.Pipeline, resultCache *cache.DomainCache) {
+	for job := range jobs {
+		if result, found := cachedResult(resultCache, job); found {
+			results <- result
+			continue
+		}
+
+		result, decided := pipeline.Screen(ctx, job.Domain)
+		result.Index = job.Index
+		if decided || ctx.Err() != nil {
+			storeResult(ctx, resultCache, &result)
+			results <- result
+			continue
+		}
+		select {
+		case next <- result:
+		case <-ctx.Done():
+			// The next stage stopped, report what the prefilter found
+			results <- result
+		}
+	}
+}
+
+// RegistryWorker runs the expensive second stage of a split pipeline on the
+// domains the prefilter could not decide, waiting delay after each one
+func RegistryWorker(ctx context.Context, id int, screened <-chan types.DomainResult, results chan<- types.DomainResult, delay time.Duration, pipeline *domain.Pipeline, resultCache *cache.DomainCache) {
+	for prior := range screened {
+		result := pipeline.CheckAfter(ctx, prior)
+		storeResult(ctx, resultCache, &result)
+		results <- result
+
+		if !pause(ctx, delay) {
+			return
+		}
+	}
+}
+
+// VerifyWorker runs the dual-source verification of the AVAILABLE results it
+// receives from queue, each once delay has passed since its first check and
+// at most concurrency at a time. It returns when stop is closed and every
+// verification it started has reported to results. A verification cut short
+// by stop or ctx is reported as unknown.
+func VerifyWorker(ctx context.Context, queue <-chan types.DomainResult, stop <-chan struct{}, results chan<- types.DomainResult, delay time.Duration, concurrency int, resultCache *cache.DomainCache) {
+	slots := make(chan struct{}, concurrency)
+	var pending sync.WaitGroup
+	defer pending.Wait()
+
+	for {
+		select {
+		case prior := <-queue:
+			pending.Add(1)
+			go func() {
+				defer pending.Done()
+				results <- verify(ctx, prior, stop, slots, delay, resultCache)
+			}()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func verify(ctx context.Context, prior types.DomainResult, stop <-chan struct{}, slots chan struct{}, delay time.Duration, resultCache *cache.DomainCache) types.DomainResult {
+	interrupted := prior
+	interrupted.Status = types.StatusUnknown
+	interrupted.Reason = "verification interrupted"
+	interrupted.Confidence = 0
+
+	timer := time.NewTimer(time.Until(prior.CheckedAt.Add(delay)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-stop:
+		return interrupted
+	case <-ctx.Done():
+		return interrupted
+	}
+
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-stop:
+		return interrupted
+	case <-ctx.Done():
+		return interrupted
+	}
+
+	result := domain.Verify(ctx, prior)
+	result.Cached = false
+	storeResult(ctx, resultCache, &result)
+	return result
+}
+
+// cachedResult answers a job from resultCache (which may be nil)
+func cachedResult(resultCache *cache.DomainCache, job types.Candidate) (types.DomainResult, bool) {
+	if resultCache == nil {
+		return types.DomainResult{}, false
+	}
+	entry, found := resultCache.Get(job.Domain)
+	if !found {
+		return types.DomainResult{}, false
+	}
+	return types.DomainResult{
+		Domain:       job.Domain,
+		Index:        job.Index,
+		Status:       entry.Status,
+		Reason:       entry.Reason,
+		Signatures:   entry.Signatures,
+		Server:       entry.Server,
+		Registration: entry.Registration,
+		Confidence:   entry.Confidence,
+		Verified:     entry.Verified,
+		CheckedAt:    entry.Timestamp,
+		Cached:       true,
+	}, true
+}
+
+// storeResult caches a result unless the check was cut short by ctx
+func storeResult(ctx context.Context, resultCache *cache.DomainCache, result *types.DomainResult) {
+	if resultCache == nil || ctx.Err() != nil {
+		return
+	}
+	err := resultCache.Set(cache.CacheEntry{
+		Domain:       result.Domain,
+		Status:       result.Status,
+		Reason:       result.Reason,
+		Signatures:   result.Signatures,
+		Server:       result.Server,
+		Registration: result.Registration,
+		Confidence:   result.Confidence,
+		Verified:     result.Verified,
+	})
+	if err != nil {
+		result.Error = err
+	}
+}
+
+// pause waits for delay, returning false if ctx was canceled meanwhile
+func pause(ctx context.Context, delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Route hands every item from in to the lane chosen by key and closes the
+// lanes once in is closed and their items are handed out. Every lane queues
+// its items and is fed by its own goroutine, so a lane whose workers are slow
+// or paused (e.g. by a breaker) never holds up the others; its queue grows
+// meanwhile. Items still queued when ctx is canceled are passed to dropped,
+// which may be nil and may be called from several lanes at once.
+func Route[T any](ctx context.Context, in <-chan T, lanes map[string]chan T, key func(T) string, dropped func(T)) {
+	queues := make(map[string]chan T, len(lanes))
+	var feeders sync.WaitGroup
+	for name, lane := range lanes {
+		queue := make(chan T)
+		queues[name] = queue
+		feeders.Add(1)
+		go func() {
+			defer feeders.Done()
+			feedLane(ctx, queue, lane, dropped)
+		}()
+	}
+	for item := range in {
+		queues[key(item)] <- item
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	feeders.Wait()
+}
+
+// feedLane queues the items it receives and hands them to lane in order,
+// closing lane when items is closed and the queue is empty. It is always
+// ready to receive, so the router never waits for a busy lane.
+func feedLane[T any](ctx context.Context, items <-chan T, lane chan<- T, dropped func(T)) {
+	defer close(lane)
+	drop := func(item T) {
+		if dropped != nil {
+			dropped(item)
+		}
+	}
+
+	var queue []T
+	for items != nil || len(queue) > 0 {
+		if ctx.Err() != nil {
+			for _, item := range queue {
+				drop(item)
+			}
+			if items != nil {
+				for item := range items {
+					drop(item)
+				}
+			}
+			return
+		}
+
+		// Only offer an item to the lane when there is one
+		var out chan<- T
+		var next T
+		if len(queue) > 0 {
+			out, next = lane, queue[0]
+		}
+		select {
+		case item, ok := <-items:
+			if !ok {
+				items = nil
+				continue
+			}
+			queue = append(queue, item)
+		case out <- next:
+			var zero T
+			queue[0] = zero // Let the item be collected
+			queue = queue[1:]
+		case <-ctx.Done():
+		}
 	}
 }
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouteKeepsOtherLanesMovingWhenOneStalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Domains interleaved by suffix, as the generator sends them
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for i := 0; i < 100; i++ {
+			in <- fmt.Sprintf("name%d.com", i)
+			in <- fmt.Sprintf("name%d.io", i)
+		}
+	}()
+
+	// Nobody reads the .com lane, as if its workers were paused
+	lanes := map[string]chan string{".com": make(chan string), ".io": make(chan string)}
+	key := func(domain string) string { return domain[strings.LastIndex(domain, "."):] }
+	var droppedMu sync.Mutex
+	var dropped []string
+	routed := make(chan struct{})
+	go func() {
+		defer close(routed)
+		Route(ctx, in, lanes, key, func(domain string) {
+			droppedMu.Lock()
+			dropped = append(dropped, domain)
+			droppedMu.Unlock()
+		})
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < 100; i++ {
+		select {
+		case domain := <-lanes[".io"]:
+			if want := fmt.Sprintf("name%d.io", i); domain != want {
+				t.Fatalf("got %s from the .io lane, want %s", domain, want)
+			}
+		case <-timeout:
+			t.Fatalf("the .io lane got %d of 100 domains while .com was stalled", i)
+		}
+	}
+	if _, open := <-lanes[".io"]; open {
+		t.Error("the .io lane should be closed once drained")
+	}
+
+	// Canceling hands the domains still queued for .com to dropped
+	cancel()
+	select {
+	case <-routed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Route did not return after ctx was canceled")
+	}
+	if len(dropped) != 100 {
+		t.Errorf("dropped %d domains, want the 100 queued for .com", len(dropped))
+	}
+	if _, open := <-lanes[".com"]; open {
+		t.Error("the .com lane should be closed")
+	}
+}
@@ -2,15 +2,29 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 
+	"domain_scanner/internal/cache"
+	"domain_scanner/internal/checkpoint"
+	"domain_scanner/internal/domain"
+	"domain_scanner/internal/evidence"
 	"domain_scanner/internal/generator"
+	"domain_scanner/internal/idn"
+	"domain_scanner/internal/lifecycle"
+	"domain_scanner/internal/output"
+	"domain_scanner/internal/ratelimit"
 	"domain_scanner/internal/types"
 	"domain_scanner/internal/worker"
 )
@@ -19,19 +33,78 @@ func printHelp() {
 	fmt.Println("Domain Scanner - A tool to check domain availability")
 	fmt.Println("\nUsage:")
 	fmt.Println("  go run main.go [options]")
+	fmt.Println("  go run main.go explain [-evidence-dir dir] <domain>   Show why a domain got its verdict")
 	fmt.Println("\nOptions:")
-	fmt.Println("  -l int      Domain length (default: 3)")
-	fmt.Println("  -s string   Domain suffix (default: .li)")
+	fmt.Println("  -l string   Domain length or range of lengths, e.g. 3 or 3-5 (default: 3)")
+	fmt.Println("  -s string   Domain suffix, or comma-separated suffixes to check every name under each of")
+	fmt.Println("              them and write a per-name TLD matrix, e.g. .com,.io,.ai,.li (default: .li)")
+	fmt.Println("  -suffix-file string File with one suffix per line, used instead of -s")
 	fmt.Println("  -p string   Domain pattern:")
 	fmt.Println("              d: Pure numbers (e.g., 123.li)")
 	fmt.Println("              D: Pure letters (e.g., abc.li)")
 	fmt.Println("              a: Alphanumeric (e.g., a1b.li)")
-	fmt.Println("  -r string   Regex filter for domain name prefix")
-	fmt.Println("  -dict string Dictionary file path (one word per line)")
+	fmt.Println("  -hyphens    Also generate labels with hyphens inside (never first or last, no -- at")
+	fmt.Println("              positions 3-4, which is reserved for xn-- IDN labels) (default: false)")
+	fmt.Println("  -m string   Positional mask instead of -l/-p, one class or literal per character:")
+	fmt.Println("              ?l letters, ?d digits, ?a alphanumeric, ?1-?9 custom charsets, [a-f0-9] inline")
+	fmt.Println("              charsets, anything else literal (e.g. x?l?l generates xaa ... xzz); Unicode")
+	fmt.Println("              characters generate IDN labels (e.g. m[uü]nchen), one script per mask")
+	fmt.Println("  -charsets string Custom charsets for -m, e.g. \"1=[aeiou],2=[bcdfg]\" or \"1=[äöü]\"")
+	fmt.Println("  -r string   Regex filter for domain name prefix; anchors, classes, repetition, alternation")
+	fmt.Println("              and literals are enumerated directly, other features filter every candidate")
+	fmt.Println("  -dict string Dictionary file path (one word per line); Unicode words are normalised and")
+	fmt.Println("              queried as punycode (xn--), words mixing scripts are skipped")
 	fmt.Println("  -delay int  Delay between queries in milliseconds (default: 1000)")
-	fmt.Println("  -workers int Number of concurrent workers (default: 10)")
+	fmt.Println("  -workers int Number of concurrent workers; with the DNS prefilter, the workers of the WHOIS/RDAP stage;")
+	fmt.Println("              with several suffixes, the workers of each suffix (default: 10)")
+	fmt.Println("  -dns-workers int Number of DNS prefilter workers; domains with a DNS delegation are reported as")
+	fmt.Println("              registered without a WHOIS/RDAP query, 0 runs all checks in one stage (default: 50)")
 	fmt.Println("  -show-registered Show registered domains in output (default: false)")
 	fmt.Println("  -force      Skip performance warnings for large domain sets (default: false)")
+	fmt.Println("  -rdap-bootstrap string IANA RDAP bootstrap file (dns.json) to use instead of the built-in copy")
+	fmt.Println("  -whois-servers string TLD to WHOIS server override file (\"<tld> <server>...\" per line)")
+	fmt.Println("  -checkpoint string Write scan progress and results to this file periodically")
+	fmt.Println("  -checkpoint-interval int Seconds between checkpoint writes (default: 30)")
+	fmt.Println("  -resume string Resume a scan from a checkpoint file (scan parameters must match);")
+	fmt.Println("              ndjson and csv result files are appended to, json cannot be resumed")
+	fmt.Println("  -cache string Persistent result cache file; domains checked within the TTL are not queried again")
+	fmt.Println("  -cache-ttl-registered duration Cache TTL for registered/reserved domains (default: 720h)")
+	fmt.Println("  -cache-ttl-available duration Cache TTL for available domains (default: 24h)")
+	fmt.Println("  -cache-ttl-unknown duration Cache TTL for unknown domains, 0 disables (default: 1h)")
+	fmt.Println("  -rate-limit float Queries per second per WHOIS/RDAP server, shared by all workers, 0 disables (default: 1)")
+	fmt.Println("  -rate-burst int Burst size per WHOIS/RDAP server (default: 3)")
+	fmt.Println("  -rate-limits string Per-server overrides, e.g. \"whois.nic.li=0.5:1,rdap.verisign.com=5\"")
+	fmt.Println("  -resolver string Comma-separated DNS upstreams used round-robin by all DNS checks instead of the")
+	fmt.Println("              system resolver: host[:port], tls://host[:port] (DNS-over-TLS) or")
+	fmt.Println("              https://host/path (DNS-over-HTTPS)")
+	fmt.Println("  -resolver-timeout duration Timeout of a single DNS query (default: 5s)")
+	fmt.Println("  -wildcard-check Resolve random names under the suffix first; if they resolve, DNS_A/DNS_MX")
+	fmt.Println("              (and SSL) evidence is ignored for the scan (default: true, disable with -wildcard-check=false)")
+	fmt.Println("  -breaker-cooldown duration Pause for a WHOIS/RDAP server that rate limits or keeps failing,")
+	fmt.Println("              doubled each time it is still unhealthy afterwards (default: 1m)")
+	fmt.Println("  -max-requeue int Times a rate limited domain is re-queued before it is reported (default: 3)")
+	fmt.Printf("  -checks string Comma-separated checkers to run, in order (default: %s)\n", strings.Join(domain.DefaultCheckers, ","))
+	fmt.Println("  -skip-checks string Comma-separated checkers to leave out of the pipeline")
+	fmt.Print("              Available checkers:")
+	for _, checker := range domain.RegisteredCheckers() {
+		fmt.Printf(" %s", checker.Name())
+	}
+	fmt.Println()
+	fmt.Println("  -output-format string Result file format: txt, ndjson, json or csv (default: txt)")
+	fmt.Println("              ndjson, json and csv write every result with its verdict, signatures,")
+	fmt.Println("              source server, latency and check time in addition to the .txt lists")
+	fmt.Println("  -output string Structured result file (default: results_<pattern>_<length>_<suffix>.<format>)")
+	fmt.Println("  -evidence-dir string Store the raw WHOIS/RDAP answers, DNS records and matched indicators")
+	fmt.Println("              behind every verdict in this directory, one <domain>.json per domain")
+	fmt.Println("  -verify     Re-check every AVAILABLE domain against RDAP, each authoritative WHOIS server and")
+	fmt.Println("              the TLD's name servers; keep it only if at least two registry sources (RDAP or")
+	fmt.Println("              WHOIS) confirm it and none disagrees, else report UNKNOWN (default: false)")
+	fmt.Println("  -verify-delay duration Time between the first check and the verification (default: 30s)")
+	fmt.Println("  -min-confidence float Confidence (0-1) an AVAILABLE verdict needs to be written to the available")
+	fmt.Println("              file; weaker ones go to unverified_domains_<pattern>_<length>_<suffix>.txt (default: 0)")
+	fmt.Println("  -drops      Drop-catching mode: classify registered domains as active, grace (expired),")
+	fmt.Println("              redemption or pending delete, estimate their drop date and write the dropping")
+	fmt.Println("              ones to dropping_domains_<pattern>_<length>_<suffix>.txt, soonest first")
 	fmt.Println("  -h          Show help information")
 	fmt.Println("\nExamples:")
 	fmt.Println("  1. Check 3-letter .li domains with 20 workers:")
@@ -50,9 +123,30 @@ func printHelp() {
 	fmt.Println("     go run main.go -dict words.txt -s .com -r \"^[a-z]{4,8}$\"")
 	fmt.Println("\n  8. Skip performance warning for large domain sets:")
 	fmt.Println("     go run main.go -l 7 -s .li -p D -force")
+	fmt.Println("\n  9. Bulk scan without the slow TLS check:")
+	fmt.Println("     go run main.go -l 4 -s .li -p D -skip-checks SSL")
+	fmt.Println("\n  10. Long scan with a checkpoint, resumed after an interruption:")
+	fmt.Println("     go run main.go -l 5 -s .li -p D -checkpoint scan.json")
+	fmt.Println("     go run main.go -l 5 -s .li -p D -resume scan.json")
+	fmt.Println("\n  11. Find short .com names that are about to drop:")
+	fmt.Println("     go run main.go -l 3 -s .com -p D -drops")
+	fmt.Println("\n  12. Keep the evidence of every verdict and audit one of them:")
+	fmt.Println("     go run main.go -l 3 -s .li -p D -evidence-dir evidence")
+	fmt.Println("     go run main.go explain -evidence-dir evidence abc.li")
+	fmt.Println("\n  13. Check all 3 to 4-letter names, hyphenated ones included:")
+	fmt.Println("     go run main.go -l 3-4 -s .li -p D -hyphens")
+	fmt.Println("\n  14. Check a word list under several TLDs and compare them per word:")
+	fmt.Println("     go run main.go -dict words.txt -s .com,.io,.ai,.li")
+	fmt.Println("\n  15. Check only consonant-vowel-consonant names with a mask:")
+	fmt.Println("     go run main.go -m \"?1?2?1\" -charsets \"1=[bcdfghjklmnpqrstvwxz],2=[aeiou]\" -s .li")
+	fmt.Println("\n  16. Check German and Chinese brand names, shown as münchen.de (xn--mnchen-3ya.de):")
+	fmt.Println("     go run main.go -dict brands.txt -s .de,.cn,.中国")
 }
 
-func showPerformanceWarning(length int, pattern string, delay int, workers int) {
+// showPerformanceWarning describes the candidate set; pattern is empty when
+// the candidates come from a -m mask, candidates is the number of domains
+// actually generated
+func showPerformanceWarning(masks []*generator.Mask, pattern string, candidates int64, delay int, workers int) {
 	var charsetSize int
 	switch pattern {
 	case "d":
@@ -65,10 +159,7 @@ func showPerformanceWarning(length int, pattern string, delay int, workers int)
 		charsetSize = 26
 	}
 
-	totalDomains := 1
-	for i := 0; i < length; i++ {
-		totalDomains *= charsetSize
-	}
+	totalDomains := int(candidates)
 
 	// 估算时间（基于延迟和worker数）
 	estimatedSeconds := (totalDomains * delay) / (workers * 1000)
@@ -78,8 +169,16 @@ func showPerformanceWarning(length int, pattern string, delay int, workers int)
 	fmt.Println("\n\033[1;33m⚠️  PERFORMANCE WARNING ⚠️\033[0m")
 	fmt.Println("═══════════════════════════════════════════════════════")
 	fmt.Printf("You are about to scan \033[1;31m%d domains\033[0m with the following settings:\n", totalDomains)
-	fmt.Printf("• Pattern: %s (charset size: %d)\n", pattern, charsetSize)
-	fmt.Printf("• Length: %d characters\n", length)
+	if pattern != "" {
+		fmt.Printf("• Pattern: %s (charset size: %d)\n", pattern, charsetSize)
+	} else {
+		fmt.Printf("• Mask: %s\n", masks[0])
+	}
+	if first, last := masks[0].Len(), masks[len(masks)-1].Len(); first != last {
+		fmt.Printf("• Length: %d-%d characters\n", first, last)
+	} else {
+		fmt.Printf("• Length: %d characters\n", first)
+	}
 	fmt.Printf("• Workers: %d\n", workers)
 	fmt.Printf("• Delay: %d ms between queries\n", delay)
 	fmt.Println()
@@ -98,13 +197,50 @@ func showPerformanceWarning(length int, pattern string, delay int, workers int)
 
 	fmt.Println("💡 \033[1;32mRecommendations:\033[0m")
 	fmt.Println("• Use regex filter (-r) to narrow down the search")
-	fmt.Println("• Consider shorter domain length (-l)")
+	if pattern != "" {
+		fmt.Println("• Consider shorter domain length (-l)")
+	} else {
+		fmt.Println("• Use literal characters or smaller charsets in the mask (-m)")
+	}
 	fmt.Println("• Increase workers (-workers) for faster processing")
 	fmt.Println("• Decrease delay (-delay) if your network can handle it")
 	fmt.Println("• Use -force flag to skip this warning next time")
 	fmt.Println("═══════════════════════════════════════════════════════")
 }
 
+// largeMaskCount is the number of candidates of a 5-letter scan, above which
+// a scan shows the performance warning
+const largeMaskCount = 26 * 26 * 26 * 26 * 26
+
+// parseLengthRange parses -l, a single length like 3 or a range like 3-5
+func parseLengthRange(value string) (int, int, error) {
+	from, to, isRange := strings.Cut(value, "-")
+	minLength, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid length %q, use a number like 3 or a range like 3-5", value)
+	}
+	maxLength := minLength
+	if isRange {
+		if maxLength, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return 0, 0, fmt.Errorf("invalid length %q, use a number like 3 or a range like 3-5", value)
+		}
+	}
+	if minLength < 1 || maxLength < minLength {
+		return 0, 0, fmt.Errorf("invalid length range %q", value)
+	}
+	return minLength, maxLength, nil
+}
+
+// maskFileName turns a mask into a file name part, e.g. x?l?l into x_l_l
+func maskFileName(mask string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '_'
+	}, mask)
+}
+
 func confirmContinue() bool {
 	fmt.Print("\nDo you want to continue? (y/N): ")
 	reader := bufio.NewReader(os.Stdin)
@@ -135,19 +271,54 @@ func showMOTD() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		os.Exit(runExplain(os.Args[2:]))
+	}
+
 	// Show MOTD
 	showMOTD()
 
 	// Define command line flags
-	length := flag.Int("l", 3, "Domain length")
-	suffix := flag.String("s", ".li", "Domain suffix")
+	length := flag.String("l", "3", "Domain length or range, e.g. 3-5")
+	suffix := flag.String("s", ".li", "Domain suffix, or comma-separated suffixes")
+	suffixFile := flag.String("suffix-file", "", "File with one domain suffix per line, used instead of -s")
 	pattern := flag.String("p", "D", "Domain pattern (d: numbers, D: letters, a: alphanumeric)")
+	hyphens := flag.Bool("hyphens", false, "Also generate labels with hyphens inside (pattern mode)")
+	maskFlag := flag.String("m", "", "Positional mask, e.g. x?l?l (overrides -l and -p)")
+	charsetsFlag := flag.String("charsets", "", "Custom charsets ?1-?9 for -m, e.g. 1=[aeiou],2=[bcdfg]")
 	regexFilter := flag.String("r", "", "Regex filter for domain names")
 	dictFile := flag.String("dict", "", "Dictionary file path (one word per line)")
 	delay := flag.Int("delay", 1000, "Delay between queries in milliseconds")
-	workers := flag.Int("workers", 10, "Number of concurrent workers")
+	workers := flag.Int("workers", 10, "Number of concurrent workers (WHOIS/RDAP stage when the DNS prefilter is on)")
+	dnsWorkers := flag.Int("dns-workers", 50, "Number of DNS prefilter workers (0 runs all checks in one stage)")
 	showRegistered := flag.Bool("show-registered", false, "Show registered domains in output")
 	force := flag.Bool("force", false, "Skip performance warnings for large domain sets")
+	rdapBootstrap := flag.String("rdap-bootstrap", "", "IANA RDAP bootstrap file (dns.json)")
+	whoisServers := flag.String("whois-servers", "", "TLD to WHOIS server override file")
+	checkpointFile := flag.String("checkpoint", "", "Write scan progress and results to this file periodically")
+	checkpointInterval := flag.Int("checkpoint-interval", 30, "Seconds between checkpoint writes")
+	resumeFile := flag.String("resume", "", "Resume a scan from a checkpoint file")
+	cacheFile := flag.String("cache", "", "Persistent result cache file")
+	cacheTTLRegistered := flag.Duration("cache-ttl-registered", 30*24*time.Hour, "Cache TTL for registered/reserved domains")
+	cacheTTLAvailable := flag.Duration("cache-ttl-available", 24*time.Hour, "Cache TTL for available domains")
+	cacheTTLUnknown := flag.Duration("cache-ttl-unknown", time.Hour, "Cache TTL for unknown domains")
+	rateLimit := flag.Float64("rate-limit", 1, "Queries per second per WHOIS/RDAP server, shared by all workers (0 disables)")
+	rateBurst := flag.Int("rate-burst", 3, "Burst size per WHOIS/RDAP server")
+	rateLimits := flag.String("rate-limits", "", "Per-server overrides: <server>=<qps>[:<burst>],...")
+	resolvers := flag.String("resolver", "", "Comma-separated DNS upstreams used round-robin by all DNS checks")
+	resolverTimeout := flag.Duration("resolver-timeout", 5*time.Second, "Timeout of a single DNS query")
+	wildcardCheck := flag.Bool("wildcard-check", true, "Detect wildcard DNS under the suffix before scanning")
+	breakerCooldown := flag.Duration("breaker-cooldown", time.Minute, "Pause for a WHOIS/RDAP server that rate limits or keeps failing")
+	maxRequeue := flag.Int("max-requeue", 3, "Times a rate limited domain is re-queued before it is reported")
+	checks := flag.String("checks", "", "Comma-separated checkers to run, in order")
+	skipChecks := flag.String("skip-checks", "", "Comma-separated checkers to leave out")
+	outputFormat := flag.String("output-format", "txt", "Result file format: txt, ndjson, json or csv")
+	outputFile := flag.String("output", "", "Structured result file (default: results_<pattern>_<length>_<suffix>.<format>)")
+	evidenceDir := flag.String("evidence-dir", "", "Directory for the raw evidence behind every verdict")
+	verify := flag.Bool("verify", false, "Confirm AVAILABLE domains with at least two independent sources")
+	verifyDelay := flag.Duration("verify-delay", 30*time.Second, "Time between the first check and the verification")
+	minConfidence := flag.Float64("min-confidence", 0, "Confidence an AVAILABLE verdict needs to be written to the available file")
+	drops := flag.Bool("drops", false, "Report registered domains that are expired, in redemption or pending delete")
 	help := flag.Bool("h", false, "Show help information")
 	flag.Parse()
 
@@ -156,23 +327,191 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Ensure suffix starts with a dot
-	if !strings.HasPrefix(*suffix, ".") {
-		*suffix = "." + *suffix
+	if *minConfidence < 0 || *minConfidence > 1 {
+		fmt.Println("Invalid -min-confidence: must be between 0 and 1")
+		os.Exit(1)
+	}
+
+	minLength, maxLength, err := parseLengthRange(*length)
+	if err != nil {
+		fmt.Printf("Invalid -l: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Candidates come from masks: either -m or one per length equivalent to -l/-p
+	var masks []*generator.Mask
+	if *maskFlag != "" {
+		charsets, err := generator.ParseCharsets(*charsetsFlag)
+		if err != nil {
+			fmt.Printf("Invalid charsets: %v\n", err)
+			os.Exit(1)
+		}
+		mask, err := generator.ParseMask(*maskFlag, charsets)
+		if err != nil {
+			fmt.Printf("Invalid mask: %v\n", err)
+			os.Exit(1)
+		}
+		masks = []*generator.Mask{mask}
+	} else if *dictFile == "" {
+		masks, err = generator.PatternMasks(*pattern, minLength, maxLength, *hyphens)
+		if err != nil {
+			fmt.Printf("Invalid pattern: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Output files are named after the pattern and length, or the mask
+	lengthName := strconv.Itoa(minLength)
+	if maxLength != minLength {
+		lengthName = fmt.Sprintf("%d-%d", minLength, maxLength)
+	}
+	scanName := fmt.Sprintf("%s_%s", *pattern, lengthName)
+	if *hyphens {
+		scanName += "_hyphens"
+	}
+	if *maskFlag != "" {
+		scanName = "mask_" + maskFileName(*maskFlag)
+	}
+
+	if *rdapBootstrap != "" {
+		if err := domain.LoadRDAPBootstrap(*rdapBootstrap); err != nil {
+			fmt.Printf("Error loading RDAP bootstrap: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *whoisServers != "" {
+		if err := domain.LoadWHOISServers(*whoisServers); err != nil {
+			fmt.Printf("Error loading WHOIS servers: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := domain.ConfigureResolver(strings.Split(*resolvers, ","), *resolverTimeout); err != nil {
+		fmt.Printf("Invalid resolver: %v\n", err)
+		os.Exit(1)
+	}
+
+	overrides, err := ratelimit.ParseOverrides(*rateLimits, *rateBurst)
+	if err != nil {
+		fmt.Printf("Invalid rate limits: %v\n", err)
+		os.Exit(1)
+	}
+	domain.SetRateLimiter(ratelimit.New(ratelimit.Limit{Rate: *rateLimit, Burst: *rateBurst}, overrides))
+	// Servers are paused after 3 failures in a row or right away when they
+	// say we are rate limited, for at most 16 times the base cooldown
+	breakers := ratelimit.NewBreakers(3, *breakerCooldown, 16**breakerCooldown)
+	domain.SetBreakers(breakers)
+
+	pipeline, err := domain.ParsePipeline(*checks, *skipChecks)
+	if err != nil {
+		fmt.Printf("Invalid check pipeline: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resultCache *cache.DomainCache
+	if *cacheFile != "" {
+		// Rate limited answers are never cached, they say nothing about the domain
+		resultCache, err = cache.OpenDomainCache(*cacheFile, *cacheTTLRegistered, map[types.Status]time.Duration{
+			types.StatusAvailable:   *cacheTTLAvailable,
+			types.StatusUnknown:     *cacheTTLUnknown,
+			types.StatusRateLimited: 0,
+		})
+		if err != nil {
+			fmt.Printf("Error opening result cache: %v\n", err)
+			os.Exit(1)
+		}
+		defer resultCache.Close()
+		// Verdicts of other check pipelines are not reused. Neither are those
+		// of scans without -drops, which decide delegated domains by DNS and
+		// so keep no registration record to classify.
+		signature := pipeline.Signature()
+		if *drops {
+			signature += ",drops"
+		}
+		resultCache.SetChecks(signature)
+		resultCache.StartCleanupRoutine(10 * time.Minute)
+	}
+
+	suffixes, err := parseSuffixes(*suffix, *suffixFile)
+	if err != nil {
+		fmt.Printf("Invalid suffixes: %v\n", err)
+		os.Exit(1)
+	}
+	// Files are named after the suffix, or the suffixes of a multi-TLD scan
+	tldName := strings.TrimPrefix(suffixes[0], ".")
+	if len(suffixes) > 1 {
+		tldName = fmt.Sprintf("%dtlds", len(suffixes))
+		if len(suffixes) <= 4 {
+			tlds := make([]string, len(suffixes))
+			for i, s := range suffixes {
+				tlds[i] = strings.TrimPrefix(s, ".")
+			}
+			tldName = strings.Join(tlds, "-")
+		}
+	}
+	// A multi-TLD scan also reports which TLDs each label is free under
+	var matrix *output.Matrix
+	if len(suffixes) > 1 {
+		matrix = output.NewMatrix(suffixes)
+	}
+
+	var resultWriter output.Writer
+	resultPath := *outputFile
+	if format := strings.ToLower(*outputFormat); format != "txt" {
+		if resultPath == "" {
+			resultPath = fmt.Sprintf("results_%s_%s.%s", scanName, tldName, format)
+		}
+		// A resumed scan adds to the results written before the interruption
+		resultWriter, err = output.NewWriter(format, resultPath, *resumeFile != "")
+		if err != nil {
+			fmt.Printf("Error opening result file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var evidenceStore *evidence.Store
+	if *evidenceDir != "" {
+		evidenceStore, err = evidence.Open(*evidenceDir)
+		if err != nil {
+			fmt.Printf("Error opening evidence directory: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Validate input modes
-	if *dictFile != "" && (*length != 3 || *pattern != "D") {
-		// Dictionary mode: length and pattern are ignored, but inform user
-		if *length != 3 || *pattern != "D" {
-			fmt.Printf("Note: When using dictionary mode, -l and -p parameters are ignored\n")
+	if *dictFile != "" && (*length != "3" || *pattern != "D" || *maskFlag != "") {
+		// Dictionary mode: length, pattern and mask are ignored, but inform user
+		if *length != "3" || *pattern != "D" || *maskFlag != "" {
+			fmt.Printf("Note: When using dictionary mode, -l, -p and -m parameters are ignored\n")
 		}
 	} else if *dictFile != "" {
 		// Pure dictionary mode
+	} else if matches, ok := generator.CountRegexMatches(masks, *regexFilter); *regexFilter != "" && ok {
+		// A regex that can be enumerated only generates its matches: warn
+		// above the size of a 5-letter scan
+		matches *= int64(len(suffixes))
+		if matches > largeMaskCount && !*force {
+			patternName := *pattern
+			if *maskFlag != "" {
+				patternName = ""
+			}
+			showPerformanceWarning(masks, patternName, matches, *delay, *workers)
+			if !confirmContinue() {
+				fmt.Println("Scan cancelled by user.")
+				os.Exit(0)
+			}
+			fmt.Println()
+		}
 	} else {
-		// Traditional pattern mode - apply performance warning
-		if *length > 5 && !*force {
-			showPerformanceWarning(*length, *pattern, *delay, *workers)
+		// Mask or pattern mode: warn above the size of a 5-letter scan,
+		// counting every length of a range and every suffix
+		candidates := generator.TotalCount(masks) * int64(len(suffixes))
+		if candidates > largeMaskCount && !*force {
+			patternName := *pattern
+			if *maskFlag != "" {
+				patternName = ""
+			}
+			showPerformanceWarning(masks, patternName, candidates, *delay, *workers)
 			if !confirmContinue() {
 				fmt.Println("Scan cancelled by user.")
 				os.Exit(0)
@@ -181,74 +520,444 @@ func main() {
 		}
 	}
 
-	domainGen := generator.GenerateDomains(*length, *suffix, *pattern, *regexFilter, *dictFile)
-	domainChan := domainGen.Domains
+	// The first Ctrl+C stops generating new domains and lets the in-flight
+	// checks finish; a second one aborts those checks as well. Either way the
+	// results collected so far are written out below.
+	scanCtx, stopScan := context.WithCancel(context.Background())
+	defer stopScan()
+	checkCtx, abortChecks := context.WithCancel(context.Background())
+	defer abortChecks()
+
+	var interrupted atomic.Bool
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		interrupted.Store(true)
+		fmt.Println("\nInterrupt received, finishing in-flight checks (press Ctrl+C again to abort them)...")
+		stopScan()
+		<-signals
+		fmt.Println("\nAborting in-flight checks...")
+		abortChecks()
+	}()
+
 	availableDomains := []string{}
 	registeredDomains := []string{}
+	unknownDomains := []string{}
+	droppingDomains := []string{} // Report lines, see lifecycle.Info.ReportLine
+	unverifiedDomains := []string{}
+
+	// Checkpointing: the tracker records which candidates are done so that an
+	// interrupted scan can continue from the first unchecked index
+	scanParams := checkpoint.Params{
+		Length:   minLength,
+		Hyphens:  *hyphens,
+		Suffix:   strings.Join(suffixes, ","),
+		Pattern:  *pattern,
+		Regex:    *regexFilter,
+		Dict:     *dictFile,
+		Mask:     *maskFlag,
+		Charsets: *charsetsFlag,
+	}
+	if maxLength != minLength {
+		scanParams.MaxLength = maxLength
+	}
+	state := checkpoint.New(scanParams)
+	checkpointPath := *checkpointFile
+	if *resumeFile != "" {
+		state, err = checkpoint.Load(*resumeFile)
+		if err != nil {
+			fmt.Printf("Error loading checkpoint: %v\n", err)
+			os.Exit(1)
+		}
+		if err := state.Validate(scanParams); err != nil {
+			fmt.Printf("Cannot resume: %v\n", err)
+			os.Exit(1)
+		}
+		if checkpointPath == "" {
+			checkpointPath = *resumeFile
+		}
+		availableDomains = append(availableDomains, state.Available...)
+		registeredDomains = append(registeredDomains, state.Registered...)
+		unknownDomains = append(unknownDomains, state.Unknown...)
+		droppingDomains = append(droppingDomains, state.Dropping...)
+		unverifiedDomains = append(unverifiedDomains, state.Unverified...)
+		if matrix != nil {
+			for domain, cell := range state.Matrix {
+				matrix.Set(domain, cell)
+			}
+		}
+		fmt.Printf("Resuming from %s: %d domains already checked, continuing at index %d\n",
+			*resumeFile, state.Checked, state.NextIndex)
+	}
+	var tracker *checkpoint.Tracker
+	if checkpointPath != "" {
+		tracker = checkpoint.NewTracker(state)
+	}
+
+	domainGen := generator.GenerateDomains(scanCtx, masks, suffixes, *regexFilter, *dictFile, state.NextIndex)
+	domainChan := domainGen.Domains
 
 	// 获取预估域名数量
 	estimatedDomains := domainGen.TotalCount
-	fmt.Printf("Checking estimated %d domains with pattern %s and length %d using %d workers...\n",
-		estimatedDomains, *pattern, *length, *workers)
+	if *maskFlag != "" && *dictFile == "" {
+		fmt.Printf("Checking %d domains with mask %s using %d workers...\n",
+			estimatedDomains, masks[0], *workers)
+	} else {
+		fmt.Printf("Checking estimated %d domains with pattern %s and length %s using %d workers...\n",
+			estimatedDomains, *pattern, *length, *workers)
+	}
 	if *regexFilter != "" {
-		fmt.Printf("Using regex filter: %s\n", *regexFilter)
+		switch {
+		case domainGen.Enumerated:
+			fmt.Printf("Using regex filter: %s (enumerating matching names directly)\n", *regexFilter)
+		case *dictFile == "":
+			fmt.Printf("Using regex filter: %s (uses features that cannot be enumerated, filtering every candidate)\n", *regexFilter)
+		default:
+			fmt.Printf("Using regex filter: %s\n", *regexFilter)
+		}
+	}
+	// Cheap checks (cost 1 or less, i.e. the local rules and DNS) run in a
+	// highly concurrent prefilter stage; only the domains it cannot decide
+	// reach the slower, rate limited registry stage
+	prefilter, registry := pipeline.Split(1)
+	staged := *dnsWorkers > 0 && prefilter != nil && registry != nil
+	if *drops {
+		// The lifecycle stage comes from the registry, so delegated domains
+		// must reach the registry stage too
+		if !slices.Contains(pipeline.Names(), "WHOIS") {
+			fmt.Println("Warning: -drops needs the WHOIS checker, no lifecycle data will be available")
+		}
+		if prefilter != nil {
+			prefilter.KeepDelegated()
+		}
+	}
+	if staged {
+		fmt.Printf("DNS stage (%d workers): %s\n", *dnsWorkers, strings.Join(prefilter.Names(), " -> "))
+		fmt.Printf("Registry stage (%d workers): %s\n", *workers, strings.Join(registry.Names(), " -> "))
+	} else {
+		fmt.Printf("Check pipeline: %s\n", strings.Join(pipeline.Names(), " -> "))
+	}
+	if *resolvers != "" {
+		fmt.Printf("DNS resolvers: %s\n", *resolvers)
+	}
+	if *rateLimit > 0 {
+		fmt.Printf("Registry rate limit: %g queries/s per server (burst %d)\n", *rateLimit, *rateBurst)
 	}
+	if len(suffixes) > 1 {
+		shown := make([]string, len(suffixes))
+		for i, suffix := range suffixes {
+			shown[i] = "." + idn.ToUnicode(strings.TrimPrefix(suffix, "."))
+		}
+		fmt.Printf("Suffixes: %s, each with its own %d registry workers\n", strings.Join(shown, " "), *workers)
+	}
+
+	// Some TLDs and resolvers answer A/MX queries for every name, which would
+	// make those checks report every domain as registered
+	var wildcards []domain.Wildcard
+	names := pipeline.Names()
+	if *wildcardCheck && (slices.Contains(names, "DNS_A") || slices.Contains(names, "DNS_MX") || slices.Contains(names, "SSL")) {
+		for _, suffix := range suffixes {
+			calibrateCtx, cancel := context.WithTimeout(scanCtx, 30*time.Second)
+			wildcard := domain.CalibrateWildcard(calibrateCtx, suffix)
+			cancel()
+			if wildcard.Detected() {
+				fmt.Printf("Wildcard DNS detected under %s (%s), ignoring %s evidence\n",
+					wildcard.Suffix, wildcardRecords(wildcard), wildcardCheckers(wildcard))
+				wildcards = append(wildcards, wildcard)
+			}
+		}
+	}
+
+	// Create a channel for domain status messages
+	statusChan := make(chan string, 1000)
 
-	// Create channels for jobs and results
-	jobs := make(chan string, 1000)
+	// Start a goroutine to print status messages
+	printerDone := make(chan struct{})
+	go func() {
+		defer close(printerDone)
+		for msg := range statusChan {
+			fmt.Println(msg)
+		}
+	}()
+
+	breakers.OnChange = func(endpoint string, open bool, cooldown time.Duration, reason string) {
+		if open {
+			statusChan <- fmt.Sprintf("[breaker] %s %s, paused for %s", endpoint, reason, cooldown)
+		} else {
+			statusChan <- fmt.Sprintf("[breaker] %s %s, resumed", endpoint, reason)
+		}
+	}
+
+	// Create channels for jobs and results. Jobs are unbuffered so that an
+	// interrupt only has to wait for the domains the workers are checking.
+	jobs := make(chan types.Candidate)
 	results := make(chan types.DomainResult, 1000)
 
 	// Start workers with WaitGroup
 	var workerWg sync.WaitGroup
-	for w := 1; w <= *workers; w++ {
+	if staged {
+		// Keep the hand-over small, so that an interrupt does not have to wait
+		// for a long backlog of registry lookups
+		screened := make(chan types.DomainResult, *workers)
+		var prefilterWg sync.WaitGroup
+		for w := 1; w <= *dnsWorkers; w++ {
+			prefilterWg.Add(1)
+			go func(id int) {
+				defer prefilterWg.Done()
+				worker.PrefilterWorker(checkCtx, id, jobs, results, screened, prefilter, resultCache)
+			}(w)
+		}
+		go func() {
+			prefilterWg.Wait()
+			close(screened)
+		}()
+
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			prefilterWg.Wait()
+		}()
+		for _, lane := range routeBySuffix(scanCtx, &workerWg, screened, suffixes, func(r types.DomainResult) string { return r.Domain }, func(r types.DomainResult) { results <- r }) {
+			for w := 1; w <= *workers; w++ {
+				workerWg.Add(1)
+				go func(id int, lane <-chan types.DomainResult) {
+					defer workerWg.Done()
+					worker.RegistryWorker(checkCtx, id, lane, results, time.Duration(*delay)*time.Millisecond, registry, resultCache)
+				}(w, lane)
+			}
+		}
+	} else {
+		for _, lane := range routeBySuffix(scanCtx, &workerWg, jobs, suffixes, func(c types.Candidate) string { return c.Domain }, nil) {
+			for w := 1; w <= *workers; w++ {
+				workerWg.Add(1)
+				go func(id int, lane <-chan types.Candidate) {
+					defer workerWg.Done()
+					worker.Worker(checkCtx, id, lane, results, time.Duration(*delay)*time.Millisecond, pipeline, resultCache)
+				}(w, lane)
+			}
+		}
+	}
+
+	// AVAILABLE domains are verified by a separate pool that reports into
+	// results as well. It runs until the feeder stops, which happens only
+	// when no verification is pending or the scan is interrupted.
+	verifyQueue := make(chan types.DomainResult)
+	feederDone := make(chan struct{})
+	if *verify {
 		workerWg.Add(1)
-		go func(id int) {
+		go func() {
 			defer workerWg.Done()
-			worker.Worker(id, jobs, results, time.Duration(*delay)*time.Millisecond)
-		}(w)
+			worker.VerifyWorker(checkCtx, verifyQueue, feederDone, results, *verifyDelay, *workers, resultCache)
+		}()
 	}
 
+	// Rate limited domains are handed back to the feeder once their server's
+	// breaker has cooled down. The feeder therefore only stops when the
+	// generator is exhausted and every dispatched domain has a final result.
+	requeue := make(chan types.Candidate)
+	var requeueWg sync.WaitGroup
+	var abandonedMu sync.Mutex
+	var abandoned []string // Re-queued domains dropped by an interrupt
+	var outstanding atomic.Int64
+	var generationDone atomic.Bool
+	allDone := make(chan struct{})
+	var allDoneOnce sync.Once
+	finishFeeding := func() { allDoneOnce.Do(func() { close(allDone) }) }
+
 	// Send jobs from domain generator
 	go func() {
+		defer close(feederDone)
 		defer close(jobs)
-		for domain := range domainChan {
-			jobs <- domain
+		send := func(candidate types.Candidate) bool {
+			select {
+			case jobs <- candidate:
+				return true
+			case <-scanCtx.Done():
+				return false
+			}
 		}
-	}()
 
-	// Create a channel for domain status messages
-	statusChan := make(chan string, 1000)
-
-	// Start a goroutine to print status messages
-	go func() {
-		for msg := range statusChan {
-			fmt.Println(msg)
+		candidates := domainChan
+		for {
+			select {
+			case candidate, ok := <-candidates:
+				if !ok {
+					candidates = nil
+					generationDone.Store(true)
+					if outstanding.Load() == 0 {
+						return
+					}
+					continue
+				}
+				if tracker != nil {
+					if tracker.Done(candidate.Index) {
+						continue // Checked out of order before the scan was interrupted
+					}
+					tracker.Dispatched(candidate.Index)
+				}
+				outstanding.Add(1)
+				if !send(candidate) {
+					return
+				}
+			case candidate := <-requeue:
+				if !send(candidate) {
+					return
+				}
+			case <-allDone:
+				return
+			case <-scanCtx.Done():
+				return
+			}
 		}
 	}()
 
 	// Collect results
+	processedCount := state.Checked
+	cachedCount := 0
+	requeueCount := make(map[string]int)
+	saveCheckpoint := func() {
+		if tracker == nil {
+			return
+		}
+		results := checkpoint.Results{
+			Available:  availableDomains,
+			Registered: registeredDomains,
+			Unknown:    unknownDomains,
+			Dropping:   droppingDomains,
+			Unverified: unverifiedDomains,
+		}
+		if matrix != nil {
+			results.Matrix = matrix.Cells()
+		}
+		err := tracker.Save(checkpointPath, results)
+		if err != nil {
+			statusChan <- fmt.Sprintf("Error saving checkpoint: %v", err)
+		}
+	}
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		processedCount := 0
+		lastCheckpoint := time.Now()
 		for result := range results {
+			if result.Status == types.StatusRateLimited && result.Error == nil &&
+				requeueCount[result.Domain] < *maxRequeue && scanCtx.Err() == nil {
+				requeueCount[result.Domain]++
+				wait := domain.ServerCooldown(result.Server)
+				if wait < time.Second {
+					wait = time.Second
+				}
+				statusChan <- fmt.Sprintf("Domain %s is RATE_LIMITED, re-queued in %s (attempt %d/%d)",
+					idn.Display(result.Domain), wait.Round(time.Second), requeueCount[result.Domain], *maxRequeue)
+
+				requeueWg.Add(1)
+				go func(candidate types.Candidate) {
+					defer requeueWg.Done()
+					timer := time.NewTimer(wait)
+					defer timer.Stop()
+					select {
+					case <-timer.C:
+						select {
+						case requeue <- candidate:
+							return
+						case <-scanCtx.Done():
+						}
+					case <-scanCtx.Done():
+					}
+					abandonedMu.Lock()
+					abandoned = append(abandoned, candidate.Domain)
+					abandonedMu.Unlock()
+				}(types.Candidate{Domain: result.Domain, Index: result.Index})
+				continue
+			}
+
+			if *verify && result.Status == types.StatusAvailable && !result.Verified && result.Error == nil {
+				select {
+				case verifyQueue <- result:
+					wait := max(time.Until(result.CheckedAt.Add(*verifyDelay)), 0)
+					statusChan <- fmt.Sprintf("Domain %s looks AVAILABLE, verifying in %s", idn.Display(result.Domain), wait.Round(time.Second))
+					continue
+				case <-feederDone:
+					// Interrupted, report the unverified verdict
+				}
+			}
+
 			processedCount++
 			progress := fmt.Sprintf("[%d]", processedCount)
-			if result.Error != nil {
-				statusChan <- fmt.Sprintf("%s Error checking domain %s: %v", progress, result.Domain, result.Error)
-				continue
+			if result.Cached {
+				cachedCount++
+				progress += " (cached)"
+			}
+			if paused := breakers.Open(); len(paused) > 0 {
+				parts := make([]string, len(paused))
+				for i, p := range paused {
+					parts[i] = fmt.Sprintf("%s %s", p.Endpoint, p.Remaining.Round(time.Second))
+				}
+				progress += " [paused: " + strings.Join(parts, ", ") + "]"
+			}
+			if resultWriter != nil {
+				if err := resultWriter.Write(result); err != nil {
+					statusChan <- fmt.Sprintf("Error writing result file: %v", err)
+				}
+			}
+			if evidenceStore != nil {
+				if err := evidenceStore.Save(result); err != nil {
+					statusChan <- fmt.Sprintf("Error writing evidence for %s: %v", idn.Display(result.Domain), err)
+				}
+			}
+
+			if matrix != nil {
+				matrix.Set(result.Domain, matrixCell(result, *minConfidence))
+			}
+
+			// Messages show IDNs in both forms, the result files as U-labels
+			shown, name := idn.Display(result.Domain), idn.ToUnicode(result.Domain)
+			switch {
+			case result.Error != nil:
+				statusChan <- fmt.Sprintf("%s Error checking domain %s: %v", progress, shown, result.Error)
+				unknownDomains = append(unknownDomains, name)
+			case result.Status == types.StatusAvailable && result.Confidence < *minConfidence:
+				statusChan <- fmt.Sprintf("%s Domain %s is AVAILABLE with low confidence %.2f, verify manually", progress, shown, result.Confidence)
+				unverifiedDomains = append(unverifiedDomains, name)
+			case result.Status == types.StatusAvailable:
+				statusChan <- fmt.Sprintf("%s Domain %s is AVAILABLE! (confidence %.2f)", progress, shown, result.Confidence)
+				availableDomains = append(availableDomains, name)
+			case result.Status.Taken():
+				if *drops {
+					info := lifecycle.Classify(name, result.Registration, time.Now())
+					if info.Stage.Dropping() {
+						statusChan <- fmt.Sprintf("%s Domain %s is %s, estimated drop %s", progress, shown, info.Stage, info.DropDate.Format("2006-01-02"))
+						droppingDomains = append(droppingDomains, info.ReportLine())
+					}
+				}
+				if *showRegistered {
+					sigStr := strings.Join(result.Signatures, ", ")
+					if result.Registration != nil && result.Registration.Expires != nil {
+						sigStr += ", expires " + result.Registration.Expires.Format("2006-01-02")
+					}
+					statusChan <- fmt.Sprintf("%s Domain %s is %s [%s]", progress, shown, result.Status, sigStr)
+					registeredDomains = append(registeredDomains, name)
+				}
+			default:
+				// Unknown and rate limited domains are always reported so they can be re-queued
+				statusChan <- fmt.Sprintf("%s Domain %s is %s: %s", progress, shown, result.Status, result.Reason)
+				unknownDomains = append(unknownDomains, name)
 			}
 
-			if result.Available {
-				statusChan <- fmt.Sprintf("%s Domain %s is AVAILABLE!", progress, result.Domain)
-				availableDomains = append(availableDomains, result.Domain)
-			} else if *showRegistered {
-				sigStr := strings.Join(result.Signatures, ", ")
-				statusChan <- fmt.Sprintf("%s Domain %s is REGISTERED [%s]", progress, result.Domain, sigStr)
-				registeredDomains = append(registeredDomains, result.Domain)
+			if outstanding.Add(-1) == 0 && generationDone.Load() {
+				finishFeeding()
+			}
+			if tracker != nil {
+				tracker.Completed(result.Index)
+				if time.Since(lastCheckpoint) >= time.Duration(*checkpointInterval)*time.Second {
+					saveCheckpoint()
+					lastCheckpoint = time.Now()
+				}
 			}
 		}
+		// Final checkpoint, also written when the scan was interrupted
+		saveCheckpoint()
 		close(statusChan)
 	}()
 
@@ -262,9 +971,26 @@ func main() {
 	}()
 
 	wg.Wait()
+	<-printerDone
+
+	// Domains still waiting to be re-queued when the scan was interrupted
+	requeueWg.Wait()
+	for _, domain := range abandoned {
+		unknownDomains = append(unknownDomains, idn.ToUnicode(domain))
+		if matrix != nil {
+			matrix.Set(domain, output.CellUnknown)
+		}
+	}
+
+	if resultWriter != nil {
+		if err := resultWriter.Close(); err != nil {
+			fmt.Printf("Error writing result file: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Save available domains to file
-	availableFile := fmt.Sprintf("available_domains_%s_%d_%s.txt", *pattern, *length, strings.TrimPrefix(*suffix, "."))
+	availableFile := fmt.Sprintf("available_domains_%s_%s.txt", scanName, tldName)
 	file, err := os.Create(availableFile)
 	if err != nil {
 		fmt.Printf("Error creating output file: %v\n", err)
@@ -281,7 +1007,7 @@ func main() {
 	}
 
 	// Save registered domains to file only if show-registered is true
-	registeredFile := fmt.Sprintf("registered_domains_%s_%d_%s.txt", *pattern, *length, strings.TrimPrefix(*suffix, "."))
+	registeredFile := fmt.Sprintf("registered_domains_%s_%s.txt", scanName, tldName)
 	if *showRegistered {
 		regFile, err := os.Create(registeredFile)
 		if err != nil {
@@ -299,19 +1025,263 @@ func main() {
 		}
 	}
 
+	// Save the available domains whose evidence is too weak to trust blindly
+	unverifiedFile := fmt.Sprintf("unverified_domains_%s_%s.txt", scanName, tldName)
+	if *minConfidence > 0 {
+		unvFile, err := os.Create(unverifiedFile)
+		if err != nil {
+			fmt.Printf("Error creating unverified domains file: %v\n", err)
+			os.Exit(1)
+		}
+		defer unvFile.Close()
+
+		for _, domain := range unverifiedDomains {
+			if _, err := unvFile.WriteString(domain + "\n"); err != nil {
+				fmt.Printf("Error writing to unverified domains file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Save the domains about to drop, soonest first
+	droppingFile := fmt.Sprintf("dropping_domains_%s_%s.txt", scanName, tldName)
+	if *drops {
+		// Report lines start with the drop date
+		slices.Sort(droppingDomains)
+		dropFile, err := os.Create(droppingFile)
+		if err != nil {
+			fmt.Printf("Error creating dropping domains file: %v\n", err)
+			os.Exit(1)
+		}
+		defer dropFile.Close()
+
+		for _, line := range droppingDomains {
+			if _, err := dropFile.WriteString(line + "\n"); err != nil {
+				fmt.Printf("Error writing to dropping domains file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Save domains without a verdict so they can be re-queued with -dict
+	unknownFile := fmt.Sprintf("unknown_domains_%s_%s.txt", scanName, tldName)
+	unkFile, err := os.Create(unknownFile)
+	if err != nil {
+		fmt.Printf("Error creating unknown domains file: %v\n", err)
+		os.Exit(1)
+	}
+	defer unkFile.Close()
+
+	for _, domain := range unknownDomains {
+		_, err := unkFile.WriteString(domain + "\n")
+		if err != nil {
+			fmt.Printf("Error writing to unknown domains file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Save the per-label matrix of a multi-TLD scan
+	matrixFile := fmt.Sprintf("matrix_%s_%s.txt", scanName, tldName)
+	if matrix != nil {
+		if err := matrix.WriteFile(matrixFile); err != nil {
+			fmt.Printf("Error writing matrix file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// 获取实际生成的域名数量
 	actualDomainsGenerated := atomic.LoadInt64(domainGen.Generated)
 	actualDomainsChecked := int(actualDomainsGenerated)
+	if interrupted.Load() || *resumeFile != "" {
+		// Generated domains that never reached a worker were not checked,
+		// and domains checked before resuming were not generated this time
+		actualDomainsChecked = processedCount
+	}
 
 	fmt.Printf("\n\nResults saved to:\n")
 	fmt.Printf("- Available domains: %s\n", availableFile)
 	if *showRegistered {
 		fmt.Printf("- Registered domains: %s\n", registeredFile)
 	}
+	if *minConfidence > 0 {
+		fmt.Printf("- Unverified available domains: %s\n", unverifiedFile)
+	}
+	fmt.Printf("- Unknown domains: %s\n", unknownFile)
+	if *drops {
+		fmt.Printf("- Dropping domains: %s\n", droppingFile)
+	}
+	if matrix != nil {
+		fmt.Printf("- TLD matrix: %s\n", matrixFile)
+	}
+	if resultWriter != nil {
+		fmt.Printf("- All results (%s): %s\n", strings.ToLower(*outputFormat), resultPath)
+	}
+	if evidenceStore != nil {
+		fmt.Printf("- Evidence: %s (go run main.go explain -evidence-dir %s <domain>)\n", *evidenceDir, *evidenceDir)
+	}
+	if tracker != nil {
+		fmt.Printf("- Checkpoint: %s\n", checkpointPath)
+	}
+	if interrupted.Load() {
+		fmt.Printf("\nScan interrupted, the results above are partial.\n")
+	}
 	fmt.Printf("\nSummary:\n")
 	fmt.Printf("- Total domains checked: %d\n", actualDomainsChecked)
 	fmt.Printf("- Available domains: %d\n", len(availableDomains))
 	if *showRegistered {
 		fmt.Printf("- Registered domains: %d\n", len(registeredDomains))
 	}
+	if *minConfidence > 0 {
+		fmt.Printf("- Unverified available domains (confidence below %.2f): %d\n", *minConfidence, len(unverifiedDomains))
+	}
+	fmt.Printf("- Unknown domains: %d\n", len(unknownDomains))
+	if *drops {
+		fmt.Printf("- Dropping domains: %d\n", len(droppingDomains))
+	}
+	if resultCache != nil {
+		fmt.Printf("- Answered from cache: %d\n", cachedCount)
+	}
+	for _, wildcard := range wildcards {
+		fmt.Printf("- Wildcard DNS under %s (%s): %s evidence was ignored\n",
+			wildcard.Suffix, wildcardRecords(wildcard), wildcardCheckers(wildcard))
+	}
+}
+
+// matrixCell is the cell a result takes in the TLD matrix
+func matrixCell(result types.DomainResult, minConfidence float64) string {
+	switch {
+	case result.Error != nil:
+		return output.CellUnknown
+	case result.Status == types.StatusAvailable && result.Confidence < minConfidence:
+		return output.CellWeakFree
+	case result.Status == types.StatusAvailable:
+		return output.CellFree
+	case result.Status.Taken():
+		return output.CellTaken
+	default:
+		return output.CellUnknown
+	}
+}
+
+// routeBySuffix gives every suffix of a multi-TLD scan its own lane, so each
+// registry is worked through by its own workers and a slow or rate limited
+// one does not hold up the others. A single suffix uses in as its only lane.
+// The router counts towards wg. Once ctx is canceled the domains still queued
+// for a lane are passed to dropped (see worker.Route) instead of being checked.
+func routeBySuffix[T any](ctx context.Context, wg *sync.WaitGroup, in <-chan T, suffixes []string, domainOf func(T) string, dropped func(T)) []<-chan T {
+	if len(suffixes) == 1 {
+		return []<-chan T{in}
+	}
+	lanes := make(map[string]chan T, len(suffixes))
+	out := make([]<-chan T, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		lane := make(chan T) // worker.Route queues for the lane
+		lanes[suffix] = lane
+		out = append(out, lane)
+	}
+	key := func(item T) string {
+		if suffix := output.SuffixOf(domainOf(item), suffixes); suffix != "" {
+			return suffix
+		}
+		return suffixes[0]
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker.Route(ctx, in, lanes, key, dropped)
+	}()
+	return out
+}
+
+// parseSuffixes returns the suffixes of -s, or of -suffix-file if given,
+// each starting with a dot and without duplicates
+func parseSuffixes(list, file string) ([]string, error) {
+	entries := strings.Split(list, ",")
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read suffix file: %w", err)
+		}
+		entries = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			if line, _, _ = strings.Cut(line, "#"); strings.TrimSpace(line) != "" {
+				entries = append(entries, line)
+			}
+		}
+	}
+
+	var suffixes []string
+	for _, entry := range entries {
+		suffix := strings.ToLower(strings.TrimSpace(entry))
+		if suffix == "" {
+			continue
+		}
+		// Queries use the A-label form of IDN suffixes such as .中国
+		ascii, err := idn.ToASCII(strings.TrimPrefix(suffix, "."))
+		if err != nil {
+			return nil, err
+		}
+		// Ensure suffix starts with a dot
+		suffix = "." + ascii
+		if !slices.Contains(suffixes, suffix) {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	if len(suffixes) == 0 {
+		return nil, fmt.Errorf("no domain suffix given")
+	}
+	return suffixes, nil
+}
+
+// wildcardRecords lists the record types a suffix answers for any name
+func wildcardRecords(wildcard domain.Wildcard) string {
+	var records []string
+	if wildcard.A {
+		records = append(records, "A")
+	}
+	if wildcard.MX {
+		records = append(records, "MX")
+	}
+	return strings.Join(records, ", ")
+}
+
+// wildcardCheckers lists the checkers whose evidence a wildcard invalidates
+func wildcardCheckers(wildcard domain.Wildcard) string {
+	var checkers []string
+	if wildcard.A {
+		checkers = append(checkers, "DNS_A", "SSL")
+	}
+	if wildcard.MX {
+		checkers = append(checkers, "DNS_MX")
+	}
+	return strings.Join(checkers, ", ")
+}
+
+// runExplain implements "explain <domain>": it prints the decision trace
+// stored by a scan run with -evidence-dir
+func runExplain(args []string) int {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	evidenceDir := flags.String("evidence-dir", "evidence", "Evidence directory of the scan")
+	flags.Usage = func() {
+		fmt.Println("Usage: go run main.go explain [-evidence-dir dir] <domain>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Evidence is stored under the A-label form of IDNs
+	name := flags.Arg(0)
+	if ascii, err := idn.ToASCII(name); err == nil {
+		name = ascii
+	}
+	record, err := evidence.Load(*evidenceDir, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	evidence.Explain(os.Stdout, record)
+	return 0
 }